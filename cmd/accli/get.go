@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/pkg/errors"
+
+	"urbanwizardry.com/kvv/internal/jsonquery"
+)
+
+func getCommand(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	label := fs.String("label", "", "label of the setting, none for the null label")
+	query := fs.String("query", "", "jq expression to apply to the (JSON) value, e.g. '.charts[] | select(.enabled)'")
+	connection := addConnectionFlags(fs)
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return errors.New("usage: accli get [--label label] [--query expression] <server> <key>")
+	}
+
+	client, err := connect(positional[0], connection)
+	if err != nil {
+		return err
+	}
+
+	options := &azappconfig.GetSettingOptions{}
+	if *label != "" {
+		options.Label = label
+	}
+
+	resp, err := client.GetSetting(context.Background(), positional[1], options)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get %s", positional[1])
+	}
+
+	value := ""
+	if resp.Value != nil {
+		value = *resp.Value
+	}
+
+	if *query != "" {
+		value, err = jsonquery.Evaluate(value, *query)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(value)
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/pkg/errors"
+)
+
+func listCommand(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	keyFilter := fs.String("key", "*", "key filter")
+	labelFilter := fs.String("label", "*", "label filter")
+	connection := addConnectionFlags(fs)
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: accli list [--key filter] [--label filter] <server>")
+	}
+
+	client, err := connect(positional[0], connection)
+	if err != nil {
+		return err
+	}
+
+	settings, err := listSettings(client, *keyFilter, *labelFilter)
+	if err != nil {
+		return err
+	}
+
+	for _, setting := range settings {
+		fmt.Printf("%s\n", *setting.Key)
+	}
+
+	return nil
+}
+
+func listSettings(client *azappconfig.Client, keyFilter string, labelFilter string) ([]azappconfig.Setting, error) {
+	settingsPager := client.NewListSettingsPager(
+		azappconfig.SettingSelector{
+			KeyFilter:   to.Ptr(keyFilter),
+			LabelFilter: to.Ptr(labelFilter),
+			Fields:      azappconfig.AllSettingFields(),
+		},
+		nil,
+	)
+
+	settings := []azappconfig.Setting{}
+
+	for settingsPager.More() {
+		resp, err := settingsPager.NextPage(context.Background())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get paged settings")
+		}
+
+		settings = append(settings, resp.Settings...)
+	}
+
+	return settings, nil
+}
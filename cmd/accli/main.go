@@ -2,70 +2,163 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
 	"github.com/pkg/errors"
+
+	"urbanwizardry.com/kvv/internal/auth"
+	"urbanwizardry.com/kvv/internal/config"
+	"urbanwizardry.com/kvv/internal/recording"
 )
 
+const usage = `Usage: accli <command> [options] <server> [args]
+
+Commands:
+  list <server>        List setting keys
+  get <server> <key>   Print a setting value
+  validate <server>    Validate setting values against their schemas
+  watch <server>       Print setting changes as JSON lines
+
+Run accli <command> -h for the options of each command.
+`
+
+// commands maps each command name to the function which runs it with the remaining arguments
+var commands = map[string]func([]string) error{
+	"list":     listCommand,
+	"get":      getCommand,
+	"validate": validateCommand,
+	"watch":    watchCommand,
+}
+
 func main() {
-	if len(os.Args) < 1 {
-		panic("no server provided")
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
 	}
 
-	configServer := os.Args[1]
-	fmt.Printf("Using: %s\n", configServer)
+	command, ok := commands[os.Args[1]]
+	args := os.Args[2:]
+	if !ok {
+		// Listing is the default, i.e. accli <server>
+		command = listCommand
+		args = os.Args[1:]
+	}
 
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
-	if err != nil {
-		log.Fatalf("failed to obtain a credential: %v", err)
+	if err := command(args); err != nil {
+		log.Fatal(err)
 	}
+}
+
+// connectionStringEnv can hold a connection string to use instead of --connection-string
+const connectionStringEnv = "ACV_CONNECTION_STRING"
+
+// connectionFlags are how a command connects to its server
+type connectionFlags struct {
+	connectionString *string
+	record           *string
+	replay           *string
+}
+
+// addConnectionFlags adds the flags for authenticating with an access key rather than Entra ID,
+// and for recording or replaying what is sent to and from the server
+func addConnectionFlags(fs *flag.FlagSet) *connectionFlags {
+	return &connectionFlags{
+		connectionString: fs.String(
+			"connection-string",
+			"",
+			fmt.Sprintf("access key connection string, rather than Entra ID (or set %s)", connectionStringEnv),
+		),
+		record: fs.String("record", "", "save every request and response to this directory, without authentication headers"),
+		replay: fs.String("replay", "", "answer requests with the responses saved to this directory by --record, instead of the server"),
+	}
+}
+
+// connect establishes a connection to the App Config server, with a connection string if there
+// is one from the command line, environment or config file, or otherwise with the Entra ID
+// credential configured for the server
+func connect(configServer string, flags *connectionFlags) (*azappconfig.Client, error) {
+	serverURL := config.ServerURL(configServer)
 
-	// Establish a connection to the Key Vault client
-	client, err := azappconfig.NewClient(configServer, cred, nil)
+	// The environment's connection string is only used for the server it's for
+	connectionString := *flags.connectionString
+	if env := os.Getenv(connectionStringEnv); connectionString == "" && config.IsConnectionStringFor(env, serverURL) {
+		connectionString = env
+	}
+
+	acvConfig, err := config.Load()
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	server := acvConfig.Server(serverURL)
+	if connectionString != "" {
+		server = config.Server{URL: server.URL, ConnectionString: connectionString}
+		if err := server.Validate(); err != nil {
+			return nil, err
+		}
 	}
 
-	revisionsPager := client.NewListSettingsPager(
-		azappconfig.SettingSelector{
-			KeyFilter:   to.Ptr("*"),
-			LabelFilter: to.Ptr("*"),
-			Fields:      azappconfig.AllSettingFields(),
+	// Sign in instructions and throttling go to stderr, to keep them out of any piped output
+	credentials := auth.NewCredentials(
+		func(message string) {
+			fmt.Fprintln(os.Stderr, message)
+		},
+		acvConfig.Retry,
+		func(ctx context.Context, host string, delay time.Duration) {
+			fmt.Fprintln(os.Stderr, throttledMessage(host, delay))
 		},
-		nil,
 	)
 
-	settings := []azappconfig.Setting{}
-
-	for revisionsPager.More() {
-		fmt.Println("page")
-		resp, err := revisionsPager.NextPage(context.Background())
+	switch {
+	case *flags.record != "" && *flags.replay != "":
+		return nil, errors.New("--record and --replay can't be used together")
+	case *flags.record != "":
+		recorder, err := recording.NewRecorder(*flags.record)
 		if err != nil {
-			panic(errors.Wrap(err, "failed to get paged secrets"))
+			return nil, err
 		}
-
-		settings = append(settings, resp.Settings...)
+		credentials.UseTransport(recorder)
+	case *flags.replay != "":
+		player, err := recording.NewPlayer(*flags.replay)
+		if err != nil {
+			return nil, err
+		}
+		credentials.Replay(player)
 	}
 
-	for _, setting := range settings {
-		fmt.Printf("%s\n", *setting.Key)
+	return credentials.NewClient(server)
+}
+
+// throttledMessage says that a server is throttling requests, and when they will be retried
+func throttledMessage(host string, delay time.Duration) string {
+	if delay == 0 {
+		return fmt.Sprintf("throttled by %s, retrying", host)
 	}
+	return fmt.Sprintf("throttled by %s, retrying in %s", host, max(delay.Round(time.Second), time.Second))
+}
 
-	// resp, err := client.GetSetting(
-	// 	context.TODO(),
-	// 	"carto/uksouth/alexanderson-3871251af/ionaDynamic.chartValues",
-	// 	&azappconfig.GetSettingOptions{
-	// 		Label: to.Ptr("alexanderson-3871251af"),
-	// 	})
+// parseInterspersed parses flags which may come before, between or after positional arguments,
+// and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
 
-	// if err != nil {
-	// 	panic(err)
-	// }
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
 
-	// fmt.Println(*resp.Key)
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
 }
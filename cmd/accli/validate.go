@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"urbanwizardry.com/kvv/internal/config"
+	"urbanwizardry.com/kvv/internal/schema"
+)
+
+func validateCommand(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	keyFilter := fs.String("key", "*", "key filter")
+	labelFilter := fs.String("label", "*", "label filter")
+	connection := addConnectionFlags(fs)
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: accli validate [--key filter] [--label filter] <server>")
+	}
+
+	acvConfig, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if len(acvConfig.Schemas) == 0 {
+		path, _ := config.Path()
+		return errors.Errorf("no schemas are mapped to keys in %s", path)
+	}
+
+	validator, err := schema.NewValidator(acvConfig.Schemas)
+	if err != nil {
+		return err
+	}
+
+	client, err := connect(positional[0], connection)
+	if err != nil {
+		return err
+	}
+
+	settings, err := listSettings(client, *keyFilter, *labelFilter)
+	if err != nil {
+		return err
+	}
+
+	checked, invalid := 0, 0
+	for _, setting := range settings {
+		if setting.Value == nil {
+			continue
+		}
+
+		result := validator.Validate(*setting.Key, setting.Label, *setting.Value)
+		if result == nil {
+			continue
+		}
+
+		checked++
+		if len(result.Violations) == 0 {
+			continue
+		}
+
+		invalid++
+		name := *setting.Key
+		if setting.Label != nil {
+			name = fmt.Sprintf("%s [%s]", name, *setting.Label)
+		}
+		for _, violation := range result.Violations {
+			fmt.Printf("%s (%s) %s\n", name, result.Schema, violation)
+		}
+	}
+
+	fmt.Printf("%d settings checked against schemas, %d invalid\n", checked, invalid)
+	if invalid > 0 {
+		return errors.Errorf("%d settings are invalid", invalid)
+	}
+
+	return nil
+}
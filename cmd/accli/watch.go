@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/pkg/errors"
+
+	"urbanwizardry.com/kvv/internal/cache"
+)
+
+// Change is one line of accli watch output, describing a setting that was added, changed or
+// removed. Values are given as hashes unless asked for, to keep secrets out of logs.
+type Change struct {
+	Time     time.Time `json:"time"`
+	Change   string    `json:"change"`
+	Key      string    `json:"key"`
+	Label    *string   `json:"label"`
+	ETag     string    `json:"etag,omitempty"`
+	OldETag  string    `json:"oldEtag,omitempty"`
+	OldHash  string    `json:"oldHash,omitempty"`
+	NewHash  string    `json:"newHash,omitempty"`
+	OldValue *string   `json:"oldValue,omitempty"`
+	NewValue *string   `json:"newValue,omitempty"`
+}
+
+func watchCommand(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	keyFilter := fs.String("key", "*", "key filter")
+	labelFilter := fs.String("label", "*", "label filter")
+	interval := fs.Duration("interval", 10*time.Second, "how often to check for changes")
+	values := fs.Bool("values", false, "include old and new values, rather than hashes of them")
+	command := fs.String("exec", "", "shell command to run for each change, given the change as JSON on stdin")
+	connection := addConnectionFlags(fs)
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: accli watch [--key filter] [--label filter] [--interval 10s] [--values] [--exec command] <server>")
+	}
+	if *interval <= 0 {
+		return errors.New("--interval must be more than zero")
+	}
+	if *command != "" && strings.TrimSpace(*command) == "" {
+		return errors.New("--exec needs a command")
+	}
+
+	client, err := connect(positional[0], connection)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// The first listing is what changes are seen against
+	listing, _, err := cache.Fetch(ctx, client, *keyFilter, *labelFilter, nil)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		latest, changed, err := cache.Fetch(ctx, client, *keyFilter, *labelFilter, listing)
+		if ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			// Keep watching through anything temporary, comparing with the last listing seen
+			log.Print(err)
+			continue
+		}
+
+		if changed {
+			for _, change := range diffListings(listing.All(), latest.All(), *values) {
+				if err := encoder.Encode(change); err != nil {
+					return errors.Wrap(err, "failed to write change")
+				}
+				if *command != "" {
+					if err := runChangeCommand(*command, change); err != nil {
+						log.Print(err)
+					}
+				}
+			}
+		}
+		listing = latest
+	}
+}
+
+// diffListings describes how settings changed between two listings
+func diffListings(before []azappconfig.Setting, after []azappconfig.Setting, values bool) []Change {
+	now := time.Now()
+	changes := []Change{}
+
+	previous := map[string]azappconfig.Setting{}
+	for _, s := range before {
+		previous[settingID(s)] = s
+	}
+
+	for _, s := range after {
+		id := settingID(s)
+		p, ok := previous[id]
+		delete(previous, id)
+
+		if !ok {
+			changes = append(changes, newChange(now, "added", nil, &s, values))
+		} else if etag(p) != etag(s) {
+			changes = append(changes, newChange(now, "changed", &p, &s, values))
+		}
+	}
+
+	// What's left is gone, reported in the order it was listed
+	for _, s := range before {
+		if _, ok := previous[settingID(s)]; ok {
+			changes = append(changes, newChange(now, "removed", &s, nil, values))
+		}
+	}
+
+	return changes
+}
+
+func newChange(now time.Time, kind string, before *azappconfig.Setting, after *azappconfig.Setting, values bool) Change {
+	setting := after
+	if setting == nil {
+		setting = before
+	}
+
+	change := Change{
+		Time:   now,
+		Change: kind,
+		Key:    *setting.Key,
+		Label:  setting.Label,
+	}
+
+	if before != nil {
+		change.OldETag = etag(*before)
+		if values {
+			change.OldValue = before.Value
+		} else {
+			change.OldHash = valueHash(before.Value)
+		}
+	}
+	if after != nil {
+		change.ETag = etag(*after)
+		if values {
+			change.NewValue = after.Value
+		} else {
+			change.NewHash = valueHash(after.Value)
+		}
+	}
+
+	return change
+}
+
+// runChangeCommand runs the --exec command for a change with sh, so that it can be quoted as in
+// a shell, with the change as JSON on stdin and in the environment as ACCLI_CHANGE, ACCLI_KEY
+// and ACCLI_LABEL
+func runChangeCommand(command string, change Change) error {
+	input, err := json.Marshal(change)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode change")
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = strings.NewReader(string(input) + "\n")
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"ACCLI_CHANGE="+change.Change,
+		"ACCLI_KEY="+change.Key,
+	)
+	if change.Label != nil {
+		cmd.Env = append(cmd.Env, "ACCLI_LABEL="+*change.Label)
+	}
+
+	return errors.Wrapf(cmd.Run(), "%s failed for %s", command, change.Key)
+}
+
+// settingID identifies a setting by key and label, keeping the null label apart from the empty one
+func settingID(s azappconfig.Setting) string {
+	if s.Label == nil {
+		return *s.Key
+	}
+	return fmt.Sprintf("%s\x00%s", *s.Key, *s.Label)
+}
+
+func etag(s azappconfig.Setting) string {
+	if s.ETag == nil {
+		return ""
+	}
+	return string(*s.ETag)
+}
+
+// valueHash is a SHA-256 of a value, which is enough to tell whether it changed
+func valueHash(value *string) string {
+	if value == nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(*value))
+	return hex.EncodeToString(sum[:])
+}
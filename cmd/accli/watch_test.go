@@ -0,0 +1,94 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+)
+
+func setting(key string, label *string, value string, etag string) azappconfig.Setting {
+	return azappconfig.Setting{Key: &key, Label: label, Value: &value, ETag: (*azcore.ETag)(&etag)}
+}
+
+func TestDiffListings(t *testing.T) {
+	prod := "prod"
+	empty := ""
+
+	tests := []struct {
+		name   string
+		before []azappconfig.Setting
+		after  []azappconfig.Setting
+		want   []string
+	}{
+		{
+			name:   "unchanged",
+			before: []azappconfig.Setting{setting("a", nil, "1", "e1")},
+			after:  []azappconfig.Setting{setting("a", nil, "1", "e1")},
+			want:   []string{},
+		},
+		{
+			name:   "added",
+			before: []azappconfig.Setting{setting("a", nil, "1", "e1")},
+			after:  []azappconfig.Setting{setting("a", nil, "1", "e1"), setting("b", nil, "2", "e2")},
+			want:   []string{"added b"},
+		},
+		{
+			name:   "changed",
+			before: []azappconfig.Setting{setting("a", nil, "1", "e1")},
+			after:  []azappconfig.Setting{setting("a", nil, "2", "e2")},
+			want:   []string{"changed a"},
+		},
+		{
+			name:   "removed in listed order",
+			before: []azappconfig.Setting{setting("a", nil, "1", "e1"), setting("b", nil, "2", "e2"), setting("c", nil, "3", "e3")},
+			after:  []azappconfig.Setting{setting("b", nil, "2", "e2")},
+			want:   []string{"removed a", "removed c"},
+		},
+		{
+			name:   "labels are separate settings",
+			before: []azappconfig.Setting{setting("a", nil, "1", "e1"), setting("a", &prod, "1", "e2")},
+			after:  []azappconfig.Setting{setting("a", &empty, "1", "e3"), setting("a", &prod, "2", "e4")},
+			want:   []string{"added a", "changed a", "removed a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes := diffListings(test.before, test.after, false)
+
+			got := []string{}
+			for _, change := range changes {
+				got = append(got, change.Change+" "+change.Key)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffListingsValues(t *testing.T) {
+	before := []azappconfig.Setting{setting("a", nil, "old", "e1")}
+	after := []azappconfig.Setting{setting("a", nil, "new", "e2")}
+
+	hashed := diffListings(before, after, false)[0]
+	if hashed.OldValue != nil || hashed.NewValue != nil {
+		t.Error("values given without --values")
+	}
+	if hashed.OldHash != valueHash(before[0].Value) || hashed.NewHash != valueHash(after[0].Value) || hashed.OldHash == hashed.NewHash {
+		t.Errorf("hashes %q and %q", hashed.OldHash, hashed.NewHash)
+	}
+	if hashed.OldETag != "e1" || hashed.ETag != "e2" {
+		t.Errorf("etags %q and %q", hashed.OldETag, hashed.ETag)
+	}
+
+	valued := diffListings(before, after, true)[0]
+	if valued.OldHash != "" || valued.NewHash != "" {
+		t.Error("hashes given with --values")
+	}
+	if *valued.OldValue != "old" || *valued.NewValue != "new" {
+		t.Errorf("values %q and %q", *valued.OldValue, *valued.NewValue)
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/pkg/errors"
+)
+
+// checkWritable returns an error if the store can't be changed right now
+func checkWritable() error {
+	if asOf != nil {
+		return errors.New("can't change settings while time travelling, clear the \"As of\" time first")
+	}
+
+	if offline {
+		return errors.New("can't change settings while offline")
+	}
+
+	return nil
+}
+
+// confirm shows the confirmation dialog over the main page, and calls confirmedFunc only if
+// the user confirms. Either way, focus goes back to the keys list afterwards.
+func confirm(title string, items []string, confirmedFunc func()) {
+	dismiss := func() {
+		pages.HidePage(ConfirmPage)
+		app.SetFocus(keysManager.keys)
+	}
+
+	confirmDialog.Setup(
+		title,
+		items,
+		func() {
+			dismiss()
+			confirmedFunc()
+		},
+		dismiss,
+	)
+
+	pages.ShowPage(ConfirmPage)
+	app.SetFocus(confirmDialog.buttons)
+}
+
+// inform shows a list of items in the confirmation dialog, where there is nothing to confirm
+func inform(title string, items []string) {
+	confirm(title, items, func() {})
+}
+
+// prompt asks for a line of text over the main page, and calls submittedFunc with it only if
+// the user doesn't cancel. Either way, focus goes back to the keys list afterwards.
+func prompt(title string, label string, initial string, submittedFunc func(string)) {
+	dismiss := func() {
+		pages.HidePage(PromptPage)
+		app.SetFocus(keysManager.keys)
+	}
+
+	promptDialog.Setup(
+		title,
+		label,
+		initial,
+		func(text string) {
+			dismiss()
+			submittedFunc(text)
+		},
+		dismiss,
+	)
+
+	pages.ShowPage(PromptPage)
+	app.SetFocus(promptDialog.form)
+}
+
+// choose offers a menu of choices over the main page. The dialog is dismissed before
+// the chosen one is run.
+func choose(title string, choices []Choice) {
+	dismiss := func() {
+		pages.HidePage(ChoicePage)
+		app.SetFocus(keysManager.keys)
+	}
+
+	choiceDialog.Setup(
+		title,
+		arraymap(choices, func(c Choice) Choice {
+			selected := c.Selected
+			c.Selected = func() {
+				dismiss()
+				selected()
+			}
+			return c
+		}),
+		dismiss,
+	)
+
+	pages.ShowPage(ChoicePage)
+	app.SetFocus(choiceDialog.list)
+}
+
+// upsertSettings swaps updated versions of settings into the fetched settings, matching
+// on key and label, adds any that are new, and redraws the keys list
+func upsertSettings(updated []azappconfig.Setting) {
+	byID := map[string]azappconfig.Setting{}
+	for _, s := range updated {
+		byID[settingID(s)] = s
+	}
+
+	settings = arraymap(settings, func(s azappconfig.Setting) azappconfig.Setting {
+		if u, ok := byID[settingID(s)]; ok {
+			delete(byID, settingID(s))
+			return u
+		}
+		return s
+	})
+
+	for _, s := range updated {
+		if _, ok := byID[settingID(s)]; ok {
+			settings = append(settings, s)
+		}
+	}
+
+	updateKeysList()
+}
+
+// updateEach applies change to each of the targets in turn, stopping at the first failure,
+// and puts whatever was changed into the keys list. It returns how many succeeded.
+func updateEach(
+	description string,
+	targets []azappconfig.Setting,
+	change func(azappconfig.Setting) (azappconfig.Setting, error),
+) int {
+	updated := []azappconfig.Setting{}
+	defer func() {
+		upsertSettings(updated)
+	}()
+
+	for _, target := range targets {
+		s, err := change(target)
+		if err != nil {
+			statusBar.SetError(errors.Wrapf(err, "failed to %s %s after %d of %d", description, settingDisplayName(target), len(updated), len(targets)))
+			return len(updated)
+		}
+
+		updated = append(updated, s)
+	}
+
+	statusBar.SetMessage(fmt.Sprintf("%d settings updated (%s)", len(updated), description))
+	return len(updated)
+}
+
+// writeSetting overwrites a setting's value and metadata, only if it is unchanged since
+// it was fetched
+func writeSetting(s azappconfig.Setting) (azappconfig.Setting, error) {
+	resp, err := client.SetSetting(
+		eventLoop,
+		*s.Key,
+		s.Value,
+		&azappconfig.SetSettingOptions{
+			Label:           s.Label,
+			ContentType:     s.ContentType,
+			Tags:            s.Tags,
+			OnlyIfUnchanged: s.ETag,
+		},
+	)
+	if err != nil {
+		return azappconfig.Setting{}, err
+	}
+
+	return resp.Setting, nil
+}
+
+// Locking
+
+func toggleLockSelected() {
+	if err := checkWritable(); err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	selected := keysManager.selectedSetting()
+	if selected == nil {
+		return
+	}
+
+	setReadOnly([]azappconfig.Setting{*selected}, !derefOr(selected.IsReadOnly, false))
+}
+
+// setReadOnlyBulk locks or unlocks every setting marked in the keys list, or every
+// listed setting if none are marked, after confirmation
+func setReadOnlyBulk(readOnly bool) {
+	setReadOnlyConfirmed(bulkTargets(), readOnly)
+}
+
+func setReadOnlyConfirmed(candidates []azappconfig.Setting, readOnly bool) {
+	if err := checkWritable(); err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	targets := reduce(candidates, func(s azappconfig.Setting) bool {
+		return derefOr(s.IsReadOnly, false) != readOnly
+	})
+
+	action := "Unlock"
+	if readOnly {
+		action = "Lock"
+	}
+
+	if len(targets) == 0 {
+		statusBar.SetMessage(fmt.Sprintf("Nothing to %s, all those settings already are", action))
+		return
+	}
+
+	confirm(
+		fmt.Sprintf("%s %d settings?", action, len(targets)),
+		arraymap(targets, settingDisplayName),
+		func() {
+			setReadOnly(targets, readOnly)
+		},
+	)
+}
+
+// setReadOnly sets the lock on each of the targets, stopping at the first failure
+func setReadOnly(targets []azappconfig.Setting, readOnly bool) {
+	description := "unlock"
+	if readOnly {
+		description = "lock"
+	}
+
+	updateEach(description, targets, func(target azappconfig.Setting) (azappconfig.Setting, error) {
+		resp, err := client.SetReadOnly(
+			eventLoop,
+			*target.Key,
+			readOnly,
+			&azappconfig.SetReadOnlyOptions{
+				Label: target.Label,
+			},
+		)
+		return resp.Setting, err
+	})
+}
+
+// Deleting
+
+// deletedBatches is the session undo buffer, holding each batch of deleted settings as they
+// were just before deletion, most recent last
+var deletedBatches [][]azappconfig.Setting
+
+func deleteSelected() {
+	selected := keysManager.selectedSetting()
+	if selected == nil {
+		return
+	}
+
+	deleteSettings([]azappconfig.Setting{*selected})
+}
+
+// deleteBulk deletes every setting marked in the keys list, or every listed
+// setting if none are marked, after confirmation
+func deleteBulk() {
+	deleteSettings(bulkTargets())
+}
+
+// deleteSettings confirms, then deletes the candidates. Locked settings can't be deleted,
+// so they are listed but skipped.
+func deleteSettings(candidates []azappconfig.Setting) {
+	if err := checkWritable(); err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	targets := reduce(candidates, func(s azappconfig.Setting) bool {
+		return !derefOr(s.IsReadOnly, false)
+	})
+
+	if len(targets) == 0 {
+		statusBar.SetMessage("Nothing to delete, locked settings must be unlocked first")
+		return
+	}
+
+	items := arraymap(candidates, func(s azappconfig.Setting) string {
+		if derefOr(s.IsReadOnly, false) {
+			return fmt.Sprintf("%s (locked, will be skipped)", settingDisplayName(s))
+		}
+		return settingDisplayName(s)
+	})
+
+	confirm(
+		fmt.Sprintf("Delete %d settings?", len(targets)),
+		items,
+		func() {
+			removeSettings(targets)
+		},
+	)
+}
+
+// removeSettings deletes each of the targets, only if it is unchanged since it was fetched,
+// stopping at the first failure. Whatever was deleted goes into the undo buffer.
+func removeSettings(targets []azappconfig.Setting) {
+	deleted := []azappconfig.Setting{}
+	defer func() {
+		if len(deleted) == 0 {
+			return
+		}
+
+		deletedBatches = append(deletedBatches, deleted)
+		removeFromSettings(deleted)
+	}()
+
+	for _, target := range targets {
+		if _, err := deleteSetting(target); err != nil {
+			statusBar.SetError(errors.Wrapf(err, "failed to delete %s after %d of %d, press u to undo", settingDisplayName(target), len(deleted), len(targets)))
+			return
+		}
+
+		deleted = append(deleted, target)
+	}
+
+	statusBar.SetMessage(fmt.Sprintf("Deleted %d settings, press u to undo", len(deleted)))
+}
+
+// deleteSetting deletes a setting, only if it is unchanged since it was fetched
+func deleteSetting(s azappconfig.Setting) (azappconfig.Setting, error) {
+	resp, err := client.DeleteSetting(
+		eventLoop,
+		*s.Key,
+		&azappconfig.DeleteSettingOptions{
+			Label:           s.Label,
+			OnlyIfUnchanged: s.ETag,
+		},
+	)
+	return resp.Setting, err
+}
+
+// removeFromSettings takes deleted settings out of the fetched settings and the keys list,
+// and clears the values view if it was showing one of them
+func removeFromSettings(deleted []azappconfig.Setting) {
+	deletedIDs := map[string]bool{}
+	for _, s := range deleted {
+		deletedIDs[settingID(s)] = true
+	}
+
+	settings = reduce(settings, func(s azappconfig.Setting) bool {
+		return !deletedIDs[settingID(s)]
+	})
+	updateKeysList()
+
+	if current := valuesManager.primaryRevisionSelector.GetCurrentRevision(); current != nil && deletedIDs[settingID(*current)] {
+		valuesManager.reset()
+	}
+}
+
+// undoDelete recreates the most recently deleted batch of settings, including their
+// content type, tags and lock
+func undoDelete() {
+	if err := checkWritable(); err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	if len(deletedBatches) == 0 {
+		statusBar.SetMessage("Nothing to undo")
+		return
+	}
+
+	batch := deletedBatches[len(deletedBatches)-1]
+	restored := []azappconfig.Setting{}
+	defer func() {
+		// Anything not restored stays in the buffer to try again
+		deletedBatches = deletedBatches[:len(deletedBatches)-1]
+		if len(restored) < len(batch) {
+			deletedBatches = append(deletedBatches, batch[len(restored):])
+		}
+
+		settings = append(settings, restored...)
+		updateKeysList()
+	}()
+
+	for _, s := range batch {
+		created, err := recreateSetting(s)
+		if created.Key != nil {
+			restored = append(restored, created)
+		}
+		if err != nil && created.Key != nil {
+			statusBar.SetError(errors.Wrapf(err, "restored %s, but it is no longer locked", settingDisplayName(s)))
+			return
+		} else if err != nil {
+			statusBar.SetError(errors.Wrapf(err, "failed to restore %s", settingDisplayName(s)))
+			return
+		}
+	}
+
+	statusBar.SetMessage(fmt.Sprintf("Restored %d settings", len(restored)))
+}
+
+// recreateSetting adds a setting that no longer exists with the same value and metadata it had.
+// It fails rather than overwrite a setting that has been created again in the meantime. If it
+// was locked and can't be locked again, the unlocked setting is returned along with the error.
+func recreateSetting(s azappconfig.Setting) (azappconfig.Setting, error) {
+	resp, err := client.AddSetting(
+		eventLoop,
+		*s.Key,
+		s.Value,
+		&azappconfig.AddSettingOptions{
+			Label:       s.Label,
+			ContentType: s.ContentType,
+			Tags:        s.Tags,
+		},
+	)
+	if err != nil {
+		return azappconfig.Setting{}, err
+	}
+
+	if !derefOr(s.IsReadOnly, false) {
+		return resp.Setting, nil
+	}
+
+	locked, err := client.SetReadOnly(
+		eventLoop,
+		*s.Key,
+		true,
+		&azappconfig.SetReadOnlyOptions{
+			Label: s.Label,
+		},
+	)
+	if err != nil {
+		return resp.Setting, errors.Wrap(err, "failed to lock")
+	}
+
+	return locked.Setting, nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/pkg/errors"
+)
+
+// exportedSetting is how a setting is written out by the export bulk action
+type exportedSetting struct {
+	Key         string            `json:"key"`
+	Label       *string           `json:"label"`
+	Value       *string           `json:"value"`
+	ContentType *string           `json:"content_type,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Locked      bool              `json:"locked"`
+}
+
+// bulkTargets are the settings bulk actions apply to: those marked in the keys list,
+// or everything listed if nothing is marked
+func bulkTargets() []azappconfig.Setting {
+	marked := keysManager.markedSettings()
+	if len(marked) > 0 {
+		return marked
+	}
+
+	return settings
+}
+
+func showBulkMenu() {
+	targets := bulkTargets()
+	if len(targets) == 0 {
+		return
+	}
+
+	choose(
+		fmt.Sprintf("%d settings", len(targets)),
+		[]Choice{
+			{"Copy to label", 'c', func() { copyToLabel(targets, false) }},
+			{"Move to label", 'm', func() { copyToLabel(targets, true) }},
+			{"Add tag", 't', func() { addTag(targets) }},
+			{"Remove tag", 'T', func() { removeTag(targets) }},
+			{"Set content type", 'y', func() { setContentType(targets) }},
+			{"Lock", 'l', func() { setReadOnlyConfirmed(targets, true) }},
+			{"Unlock", 'u', func() { setReadOnlyConfirmed(targets, false) }},
+			{"Delete", 'x', func() { deleteSettings(targets) }},
+			{"Export to file", 'e', func() { exportSettings(targets) }},
+		},
+	)
+}
+
+// parseLabel turns label input into a label, where nothing means the null label
+func parseLabel(text string) *string {
+	text = strings.TrimSpace(text)
+	if text == "" || text == labelText(nil) {
+		return nil
+	}
+	return &text
+}
+
+// copyToLabel writes each of the targets under another label, after showing which will
+// overwrite settings already there. When moving, the originals are deleted afterwards, and
+// can be restored with undo.
+func copyToLabel(targets []azappconfig.Setting, move bool) {
+	if err := checkWritable(); err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	action := "Copy"
+	if move {
+		action = "Move"
+	}
+
+	prompt(fmt.Sprintf("%s %d settings to label", action, len(targets)), "Label: ", "", func(text string) {
+		label := parseLabel(text)
+		planCopyToLabel(targets, label, move, action)
+	})
+}
+
+// planCopyToLabel checks what is already under the label, and asks for confirmation, refusing
+// if any target is already under the label or would overwrite a locked setting
+func planCopyToLabel(targets []azappconfig.Setting, label *string, move bool, action string) {
+	existing, err := listSettings(client, "*", labelFilter(label), nil)
+	if err != nil {
+		statusBar.SetError(err)
+		return
+	}
+	existingSettings := map[string]azappconfig.Setting{}
+	for _, s := range existing {
+		existingSettings[settingID(s)] = s
+	}
+
+	blocked, overwrites := 0, 0
+	plan := arraymap(targets, func(s azappconfig.Setting) string {
+		copied := s
+		copied.Label = label
+		line := fmt.Sprintf("%s -> [%s]", settingDisplayName(s), labelText(label))
+
+		if settingID(copied) == settingID(s) {
+			blocked++
+			return fmt.Sprintf("%s (BLOCKED: already has this label)", line)
+		}
+		target, ok := existingSettings[settingID(copied)]
+		if !ok {
+			return line
+		}
+		if derefOr(target.IsReadOnly, false) {
+			blocked++
+			return fmt.Sprintf("%s (BLOCKED: target is locked)", line)
+		}
+		overwrites++
+		return fmt.Sprintf("%s (overwrites existing value)", line)
+	})
+
+	if blocked > 0 {
+		inform(fmt.Sprintf("Can't %s, %d of %d settings are blocked", strings.ToLower(action), blocked, len(targets)), plan)
+		return
+	}
+
+	title := fmt.Sprintf("%s %d settings to [%s]?", action, len(targets), labelText(label))
+	if overwrites > 0 {
+		title = fmt.Sprintf("%s %d settings to [%s], overwriting %d?", action, len(targets), labelText(label), overwrites)
+	}
+
+	confirm(title, plan, func() {
+		copied := updateEach(strings.ToLower(action), targets, func(s azappconfig.Setting) (azappconfig.Setting, error) {
+			s.Label = label
+			s.ETag = nil
+			return writeSetting(s)
+		})
+
+		if move && copied == len(targets) {
+			removeSettings(targets)
+		}
+	})
+}
+
+func addTag(targets []azappconfig.Setting) {
+	if err := checkWritable(); err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	prompt(fmt.Sprintf("Add tag to %d settings", len(targets)), "name=value: ", "", func(text string) {
+		name, value, found := strings.Cut(text, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			statusBar.SetError(errors.Errorf("tag %q should be name=value", text))
+			return
+		}
+
+		updateEach("tag", targets, func(s azappconfig.Setting) (azappconfig.Setting, error) {
+			s.Tags = maps.Clone(s.Tags)
+			if s.Tags == nil {
+				s.Tags = map[string]*string{}
+			}
+			s.Tags[name] = &value
+			return writeSetting(s)
+		})
+	})
+}
+
+func removeTag(targets []azappconfig.Setting) {
+	if err := checkWritable(); err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	prompt(fmt.Sprintf("Remove tag from %d settings", len(targets)), "Name: ", "", func(text string) {
+		name := strings.TrimSpace(text)
+
+		// Only touch the settings which have the tag
+		tagged := reduce(targets, func(s azappconfig.Setting) bool {
+			_, ok := s.Tags[name]
+			return ok
+		})
+
+		updateEach("untag", tagged, func(s azappconfig.Setting) (azappconfig.Setting, error) {
+			s.Tags = maps.Clone(s.Tags)
+			delete(s.Tags, name)
+			return writeSetting(s)
+		})
+	})
+}
+
+func setContentType(targets []azappconfig.Setting) {
+	if err := checkWritable(); err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	prompt(fmt.Sprintf("Set content type of %d settings", len(targets)), "Content type: ", "application/json", func(text string) {
+		contentType := strings.TrimSpace(text)
+
+		updateEach("set content type", targets, func(s azappconfig.Setting) (azappconfig.Setting, error) {
+			s.ContentType = &contentType
+			return writeSetting(s)
+		})
+	})
+}
+
+// exportSettings writes the targets, with their metadata, to a JSON file
+func exportSettings(targets []azappconfig.Setting) {
+	filename := fmt.Sprintf("acv-export-%s.json", time.Now().Format("20060102-150405"))
+
+	prompt(fmt.Sprintf("Export %d settings", len(targets)), "File: ", filename, func(text string) {
+		exported := arraymap(targets, func(s azappconfig.Setting) exportedSetting {
+			tags := map[string]string{}
+			for name, value := range s.Tags {
+				tags[name] = derefOr(value, "")
+			}
+
+			return exportedSetting{
+				Key:         *s.Key,
+				Label:       s.Label,
+				Value:       s.Value,
+				ContentType: s.ContentType,
+				Tags:        tags,
+				Locked:      derefOr(s.IsReadOnly, false),
+			}
+		})
+
+		data, err := json.MarshalIndent(exported, "", "  ")
+		if err != nil {
+			statusBar.SetError(errors.Wrap(err, "failed to export settings"))
+			return
+		}
+
+		if err := os.WriteFile(text, data, 0o600); err != nil {
+			statusBar.SetError(errors.Wrap(err, "failed to export settings"))
+			return
+		}
+
+		statusBar.SetMessage(fmt.Sprintf("Exported %d settings to %s", len(exported), text))
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/pkg/errors"
+
+	"urbanwizardry.com/kvv/internal/cache"
+)
+
+var (
+	// settingsCache keeps live settings and revisions on disk, or is nil if there's nowhere to
+	settingsCache *cache.Cache
+
+	// offline is true when only the cache is used, and nothing is fetched from servers
+	offline bool
+)
+
+// errOffline is returned for anything that can't be done from the cache
+var errOffline = errors.New("can't do that while offline")
+
+// fetchCachedSettings shows the cached live settings straight away, if there are any, and then
+// checks them against the server in the background. Otherwise they are fetched and cached.
+func fetchCachedSettings() error {
+	cached, err := settingsCache.Settings(currentServer, currentLabelFilter)
+	if err != nil {
+		// Fetch them again to replace the broken cache
+		statusBar.SetError(err)
+	}
+
+	if cached != nil {
+		settings = cached.All()
+		refreshed = cached.Fetched
+		if !offline {
+			go checkCachedSettings(client, currentServer, currentLabelFilter, cached)
+		}
+		return nil
+	}
+
+	if offline {
+		return errors.Errorf("%s hasn't been cached, open it while online first", currentServer)
+	}
+
+	listing, _, err := cache.Fetch(eventLoop, client, "*", currentLabelFilter, nil)
+	if err != nil {
+		return err
+	}
+
+	settings = listing.All()
+	refreshed = listing.Fetched
+	go saveCachedSettings(currentServer, currentLabelFilter, listing)
+	return nil
+}
+
+// checkCachedSettings fetches any pages of cached settings which have changed on the server,
+// and shows the changes if the settings are still being looked at
+func checkCachedSettings(serverClient *azappconfig.Client, server string, labelFilter string, cached *cache.Listing) {
+	listing, changed, err := cache.Fetch(context.Background(), serverClient, "*", labelFilter, cached)
+	if err != nil {
+		app.QueueUpdateDraw(func() {
+			statusBar.SetError(errors.Wrap(err, "showing cached settings, failed to check for changes"))
+		})
+		return
+	}
+
+	saveCachedSettings(server, labelFilter, listing)
+
+	app.QueueUpdateDraw(func() {
+		if server != currentServer || labelFilter != currentLabelFilter || asOf != nil {
+			// Moved on to something else, which will pick up the updated cache when it's next looked at
+			return
+		}
+
+		refreshed = listing.Fetched
+		if changed {
+			replaceSettings(listing.All())
+			statusBar.SetMessage("Settings have changed since they were cached, updated the keys list")
+		}
+		updateKeysTitle()
+	})
+}
+
+func saveCachedSettings(server string, labelFilter string, listing *cache.Listing) {
+	if err := settingsCache.SaveSettings(server, labelFilter, listing); err != nil {
+		app.QueueUpdateDraw(func() {
+			statusBar.SetError(err)
+		})
+	}
+}
+
+// getCachedRevisions fetches the live revisions of a setting and caches them, unless they
+// have been prefetched, or when offline returns the cached ones
+func getCachedRevisions(setting azappconfig.Setting, client *azappconfig.Client) ([]azappconfig.Setting, error) {
+	if revisions, ok := prefetcher.Revisions(currentServer, setting); ok {
+		return revisions, nil
+	}
+
+	if offline {
+		cached, err := settingsCache.Revisions(currentServer, *setting.Key, setting.Label)
+		if err != nil {
+			return nil, err
+		}
+		if cached == nil {
+			return nil, errors.Errorf("revisions of %s haven't been cached", settingDisplayName(setting))
+		}
+		return cached.Revisions, nil
+	}
+
+	revisions, err := listRevisions(eventLoop, client, escapeFilter(*setting.Key), labelFilter(setting.Label), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := settingsCache.SaveRevisions(currentServer, *setting.Key, setting.Label, revisions); err != nil {
+		statusBar.SetError(err)
+	}
+	prefetcher.Store(currentServer, setting, revisions)
+	return revisions, nil
+}
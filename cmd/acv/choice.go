@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Choice is one of the options offered by a ChoiceDialog
+type Choice struct {
+	Name     string
+	Shortcut rune
+	Selected func()
+}
+
+// ChoiceDialog offers a menu of things to do, e.g. the bulk actions
+type ChoiceDialog struct {
+	// UI Layout
+	layout tview.Primitive
+	list   *tview.List
+
+	// Events and Callbacks
+	cancelFunc func()
+}
+
+var _ UIComponent = (*ChoiceDialog)(nil)
+
+func NewChoiceDialog() *ChoiceDialog {
+	dialog := &ChoiceDialog{}
+
+	dialog.list = tview.NewList().
+		SetMainTextStyle(UIStyles.TableCellBlur).
+		SetShortcutStyle(UIStyles.TableCellMarked).
+		SetSelectedStyle(UIStyles.DropdownFocus).
+		ShowSecondaryText(false)
+
+	dialog.list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			dialog.cancelFunc()
+			return nil
+		}
+		return event
+	})
+
+	dialog.list.
+		SetBorderPadding(1, 1, 1, 1).
+		SetBorder(true).
+		SetBorderColor(tcell.ColorBlue).
+		SetBackgroundColor(tcell.ColorBlack)
+
+	dialog.layout = centered(dialog.list, 50, 15)
+
+	return dialog
+}
+
+func (cd *ChoiceDialog) GetPrimitive() tview.Primitive {
+	return cd.layout
+}
+
+// Setup fills the dialog with choices. Selecting one calls its Selected func, and
+// escaping calls cancelFunc.
+func (cd *ChoiceDialog) Setup(title string, choices []Choice, cancelFunc func()) {
+	cd.cancelFunc = cancelFunc
+
+	cd.list.Clear()
+	cd.list.SetTitle(fmt.Sprintf(" %s ", tview.Escape(title)))
+	for _, choice := range choices {
+		cd.list.AddItem(choice.Name, "", choice.Shortcut, choice.Selected)
+	}
+	cd.list.SetCurrentItem(0)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// ConfirmDialog asks for confirmation before doing something to the store, listing
+// exactly which items will be affected
+type ConfirmDialog struct {
+	// UI Layout
+	layout   tview.Primitive
+	frame    *tview.Flex
+	itemList *tview.TextView
+	buttons  *tview.Form
+
+	// Events and Callbacks
+	confirmFunc func()
+	cancelFunc  func()
+}
+
+var _ UIComponent = (*ConfirmDialog)(nil)
+
+func NewConfirmDialog() *ConfirmDialog {
+	dialog := &ConfirmDialog{}
+
+	dialog.itemList = tview.NewTextView().SetDynamicColors(true)
+	dialog.itemList.SetBorderPadding(1, 0, 1, 1)
+
+	dialog.buttons = tview.NewForm().
+		SetButtonsAlign(tview.AlignCenter).
+		SetButtonBackgroundColor(tcell.ColorBlue).
+		AddButton("Confirm", func() {
+			dialog.confirmFunc()
+		}).
+		AddButton("Cancel", func() {
+			dialog.cancelFunc()
+		})
+
+	dialog.buttons.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			dialog.cancelFunc()
+			return nil
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+			// The list isn't focusable, so let it be scrolled from here
+			dialog.itemList.InputHandler()(event, nil)
+			return nil
+		}
+		return event
+	})
+
+	dialog.frame = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(dialog.itemList, 0, 1, false).
+		AddItem(dialog.buttons, 3, 0, true)
+	dialog.frame.
+		SetBorder(true).
+		SetBorderColor(tcell.ColorBlue).
+		SetBackgroundColor(tcell.ColorBlack)
+
+	dialog.layout = centered(dialog.frame, 100, 24)
+
+	return dialog
+}
+
+func (cd *ConfirmDialog) GetPrimitive() tview.Primitive {
+	return cd.layout
+}
+
+// Setup prepares the dialog to ask a question about a list of items. Exactly one of
+// confirmFunc or cancelFunc will be called when the user responds.
+func (cd *ConfirmDialog) Setup(
+	title string,
+	items []string,
+	confirmFunc func(),
+	cancelFunc func(),
+) {
+	cd.confirmFunc = confirmFunc
+	cd.cancelFunc = cancelFunc
+
+	cd.frame.SetTitle(fmt.Sprintf(" %s ", tview.Escape(title)))
+	cd.itemList.SetText(strings.Join(arraymap(items, tview.Escape), "\n"))
+	cd.itemList.ScrollToBeginning()
+	cd.buttons.SetFocus(0)
+}
+
+// centered places p in the middle of the screen at the given size, for use as a modal page
+func centered(p tview.Primitive, width int, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().
+			SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+}
@@ -1,20 +1,44 @@
 package main
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/gdamore/tcell/v2"
+	"github.com/pkg/errors"
 	"github.com/rivo/tview"
+
+	"urbanwizardry.com/kvv/internal/config"
 )
 
+// timeTravelFormats are the accepted layouts for the time travel input, tried in order.
+// All but RFC3339 are interpreted in the local time zone
+var timeTravelFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
 type Header struct {
-	grid       *tview.Grid
-	escapeFunc func()
-	acDropdown *tview.DropDown
+	grid            *tview.Grid
+	escapeFunc      func()
+	acDropdown      *tview.DropDown
+	timeTravelInput *tview.InputField
+	tabBar          *tview.TextView
+
+	// servers are the URLs of the dropdown's options, in order
+	servers []string
 }
 
 func NewHeader(
-	configServers []string,
+	configServers []config.Server,
 	escapeFunc func(),
 	serverSelectedFunc func(string),
+	timeTravelFunc func(*time.Time),
+	timeTravelErrorFunc func(error),
 ) *Header {
 
 	header := Header{
@@ -44,12 +68,51 @@ func NewHeader(
 		})
 	header.acDropdown.SetBorder(true)
 
-	for _, vaultUri := range configServers {
-		header.acDropdown.AddOption(vaultUri, func() {
-			serverSelectedFunc(vaultUri)
+	// Show how each server is authenticated with, as that affects what can be done
+	for _, server := range configServers {
+		header.servers = append(header.servers, server.URL)
+		header.acDropdown.AddOption(fmt.Sprintf("%s (%s)", server.URL, server.AuthMode()), func() {
+			serverSelectedFunc(server.URL)
 		})
 	}
 
+	// Point-in-time control, empty means "now"
+	header.timeTravelInput = tview.NewInputField().
+		SetLabel("As of: ").
+		SetPlaceholder("now").
+		SetFieldBackgroundColor(tcell.ColorBlack).
+		SetPlaceholderStyle(
+			tcell.Style{}.
+				Background(tcell.ColorBlack).
+				Foreground(tcell.ColorGray),
+		)
+
+	header.timeTravelInput.
+		SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			switch event.Key() {
+			case tcell.KeyEscape:
+				escapeFunc()
+				return nil
+			case tcell.KeyEnter:
+				asOf, err := parseTimeTravel(header.timeTravelInput.GetText())
+				if err != nil {
+					timeTravelErrorFunc(err)
+					return nil
+				}
+				timeTravelFunc(asOf)
+				return nil
+			}
+			return event
+		})
+	header.timeTravelInput.SetBorder(true)
+	header.SetHistorical(nil)
+
+	// One entry per tab, with the current one picked out
+	header.tabBar = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	header.tabBar.SetBackgroundColor(tcell.ColorBlack)
+
 	menu := NewShortcutMenu()
 
 	logo := tview.NewTextArea().
@@ -57,11 +120,13 @@ func NewHeader(
 		SetTextStyle(UIStyles.VanityLogoStyle)
 
 	header.grid = tview.NewGrid().
-		SetRows(3, 0).
-		SetColumns(0, 23).
-		AddItem(header.acDropdown, 0, 0, 1, 1, 0, 0, false).
-		AddItem(menu.GetPrimitive(), 1, 0, 1, 1, 0, 0, false).
-		AddItem(logo, 0, 1, 2, 1, 0, 0, false)
+		SetRows(1, 3, 0).
+		SetColumns(0, 36, 23).
+		AddItem(header.tabBar, 0, 0, 1, 2, 0, 0, false).
+		AddItem(header.acDropdown, 1, 0, 1, 1, 0, 0, false).
+		AddItem(header.timeTravelInput, 1, 1, 1, 1, 0, 0, false).
+		AddItem(menu.GetPrimitive(), 2, 0, 1, 2, 0, 0, false).
+		AddItem(logo, 0, 2, 3, 1, 0, 0, false)
 	header.grid.SetBackgroundColor(tcell.ColorBlack)
 
 	return &header
@@ -74,3 +139,74 @@ func (h *Header) GetPrimitive() tview.Primitive {
 func (h *Header) SelectFirstServer() {
 	h.acDropdown.SetCurrentOption(0)
 }
+
+// SelectServer shows a server as the selected one, which selects it if it isn't already
+func (h *Header) SelectServer(server string) {
+	if i := slices.Index(h.servers, server); i >= 0 {
+		h.acDropdown.SetCurrentOption(i)
+	}
+}
+
+// SetTabs lists the names of the open tabs, highlighting the current one
+func (h *Header) SetTabs(names []string, current int) {
+	text := ""
+	for i, name := range names {
+		if i == current {
+			text += fmt.Sprintf("[black:blue] %d %s [-:-]", i+1, tview.Escape(name))
+		} else {
+			text += fmt.Sprintf("[gray] %d %s [-]", i+1, tview.Escape(name))
+		}
+	}
+	h.tabBar.SetText(text)
+}
+
+// SetHistorical marks the time travel control as showing a point in time, or
+// as live when asOf is nil
+func (h *Header) SetHistorical(asOf *time.Time) {
+	if asOf == nil {
+		h.timeTravelInput.SetText("")
+		h.timeTravelInput.SetTitle("")
+		h.timeTravelInput.SetBorderColor(tcell.ColorWhite)
+		h.timeTravelInput.SetFieldTextColor(tcell.ColorAntiqueWhite)
+		return
+	}
+
+	h.timeTravelInput.SetText(asOf.Format(timeTravelFormats[1]))
+	h.timeTravelInput.SetTitle(" HISTORICAL ")
+	h.timeTravelInput.SetTitleColor(tcell.ColorRed)
+	h.timeTravelInput.SetBorderColor(tcell.ColorRed)
+	h.timeTravelInput.SetFieldTextColor(tcell.ColorRed)
+}
+
+// parseTimeTravel turns the time travel input into a point in time. An empty string
+// means "now" and returns nil. Durations such as "24h" are taken as that long ago.
+func parseTimeTravel(text string) (*time.Time, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || text == "now" {
+		return nil, nil
+	}
+
+	var asOf time.Time
+	if ago, err := time.ParseDuration(text); err == nil {
+		asOf = time.Now().Add(-ago)
+	} else {
+		parsed := false
+		for _, format := range timeTravelFormats {
+			if t, err := time.ParseInLocation(format, text, time.Local); err == nil {
+				asOf = t
+				parsed = true
+				break
+			}
+		}
+
+		if !parsed {
+			return nil, errors.Errorf("can't understand time %q, try 2006-01-02 15:04 or 24h", text)
+		}
+	}
+
+	if asOf.After(time.Now()) {
+		return nil, errors.New("can't time travel into the future")
+	}
+
+	return &asOf, nil
+}
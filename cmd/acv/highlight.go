@@ -0,0 +1,554 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// Syntax is the language a value is highlighted as
+type Syntax int
+
+const (
+	NoSyntax Syntax = iota
+	JsonSyntax
+	YamlSyntax
+	XmlSyntax
+	IniSyntax
+)
+
+type tokenKind int
+
+const (
+	tokenKey tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenBool
+	tokenNull
+	tokenComment
+	tokenTag
+)
+
+var syntaxColors = map[tokenKind]string{
+	tokenKey:     "lightskyblue",
+	tokenString:  "lightgreen",
+	tokenNumber:  "orange",
+	tokenBool:    "violet",
+	tokenNull:    "lightcoral",
+	tokenComment: "gray",
+	tokenTag:     "dodgerblue",
+}
+
+// Backgrounds of the lines removed and added by a diff
+const (
+	diffRemovedBackground = "#5f0000"
+	diffAddedBackground   = "#005f00"
+)
+
+var (
+	numberPattern = regexp.MustCompile(`^[-+]?(\d[\d_]*)?(\.\d+)?([eE][-+]?\d+)?$`)
+	boolWords     = []string{"true", "false", "yes", "no", "on", "off"}
+	nullWords     = []string{"null", "~"}
+)
+
+// span is a highlighted part of a line, by byte offset
+type span struct {
+	start int
+	end   int
+	kind  tokenKind
+}
+
+// markupLine is a line of plain text to be displayed
+type markupLine struct {
+	text string
+	// prefix is the length of any diff marker at the start of the line, which isn't part of the value
+	prefix int
+	// background colours the whole line, e.g. for a diff
+	background string
+	// color is the colour of text which isn't highlighted as syntax
+	color string
+}
+
+// searchRegions puts the matches of a search into regions m0, m1, ... in the order they are found
+type searchRegions struct {
+	find  func(string) [][]int
+	count int
+}
+
+// matchRegion is the region ID of a search match
+func matchRegion(index int) string {
+	return fmt.Sprintf("m%d", index)
+}
+
+// syntaxFor is how a value rendered with a render type should be highlighted
+func syntaxFor(t RenderType, value string) Syntax {
+	switch t {
+	case Json, JsonTree, Jwt:
+		return JsonSyntax
+	case Yaml:
+		return YamlSyntax
+	case Xml:
+		return XmlSyntax
+	case Toml:
+		return IniSyntax
+	}
+
+	return guessSyntax(value)
+}
+
+// guessSyntax spots values that are obviously JSON, XML or INI even when shown as plain text
+func guessSyntax(value string) Syntax {
+	trimmed := strings.TrimSpace(value)
+	switch {
+	case trimmed == "":
+		return NoSyntax
+	case (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)):
+		return JsonSyntax
+	case strings.HasPrefix(trimmed, "<") && strings.HasSuffix(trimmed, ">"):
+		return XmlSyntax
+	case looksLikeIni(trimmed):
+		return IniSyntax
+	}
+
+	return NoSyntax
+}
+
+// looksLikeIni is true of text made up of sections, comments and key=value lines. A single
+// key=value line isn't enough, as that's more likely to be e.g. a connection string.
+func looksLikeIni(text string) bool {
+	lines := strings.Split(text, "\n")
+	sections, pairs := 0, 0
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			sections++
+		case strings.Index(line, "=") > 0:
+			pairs++
+		default:
+			return false
+		}
+	}
+
+	return sections > 0 || pairs > 1
+}
+
+// plainLines splits plain text into lines for markup
+func plainLines(text string) []markupLine {
+	return arraymap(strings.Split(text, "\n"), func(s string) markupLine {
+		return markupLine{text: s}
+	})
+}
+
+// diffLines splits a line diff into lines for markup, with removed and added lines given backgrounds
+func diffLines(lines []string) []markupLine {
+	return arraymap(lines, func(s string) markupLine {
+		line := markupLine{text: s, prefix: min(1, len(s))}
+		if strings.HasPrefix(s, "-") {
+			line.background = diffRemovedBackground
+		} else if strings.HasPrefix(s, "+") {
+			line.background = diffAddedBackground
+		}
+		return line
+	})
+}
+
+// markup converts lines of plain text to tview markup. The text is escaped, syntax is highlighted,
+// and each search match (if searching) is put in its own region.
+func markup(lines []markupLine, syntax Syntax, search *searchRegions) string {
+	h := &highlighter{syntax: syntax, blockIndent: -1}
+
+	return strings.Join(arraymap(lines, func(line markupLine) string {
+		colors := make([]string, len(line.text))
+		for i := range colors {
+			colors[i] = line.color
+		}
+		for _, s := range h.spans(line.text[line.prefix:]) {
+			for i := s.start; i < s.end; i++ {
+				colors[line.prefix+i] = syntaxColors[s.kind]
+			}
+		}
+
+		found := make([]int, len(line.text))
+		for i := range found {
+			found[i] = -1
+		}
+		if search != nil {
+			for _, match := range search.find(line.text) {
+				for i := match[0]; i < match[1]; i++ {
+					found[i] = search.count
+				}
+				search.count++
+			}
+		}
+
+		return markupRuns(line, colors, found)
+	}), "\n")
+}
+
+// markupRuns writes a line as runs of text which look the same, with tags between them
+func markupRuns(line markupLine, colors []string, found []int) string {
+	background := "-"
+	if line.background != "" {
+		background = line.background
+	}
+
+	var b strings.Builder
+	if line.background != "" {
+		b.WriteString(fmt.Sprintf("[-:%s]", background))
+	}
+
+	// Search matches are underlined, and the current match is also highlighted by its region
+	color, region, styled := "", -1, false
+	for i := 0; i < len(line.text); {
+		j := i + 1
+		for j < len(line.text) && colors[j] == colors[i] && found[j] == found[i] {
+			j++
+		}
+
+		if found[i] != region {
+			if found[i] >= 0 {
+				b.WriteString(fmt.Sprintf(`["%s"]`, matchRegion(found[i])))
+			} else {
+				b.WriteString(`[""]`)
+			}
+		}
+
+		if colors[i] != color || (found[i] >= 0) != (region >= 0) {
+			foreground, attributes := colors[i], "-"
+			if foreground == "" {
+				foreground = "-"
+			}
+			if found[i] >= 0 {
+				attributes = "bu"
+			}
+			b.WriteString(fmt.Sprintf("[%s:%s:%s]", foreground, background, attributes))
+			color = colors[i]
+			styled = true
+		}
+		region = found[i]
+
+		b.WriteString(tview.Escape(line.text[i:j]))
+		i = j
+	}
+
+	if region >= 0 {
+		b.WriteString(`[""]`)
+	}
+	if styled || line.background != "" {
+		b.WriteString("[-:-:-]")
+	}
+
+	return b.String()
+}
+
+// highlighter finds the tokens of a value line by line, keeping what little state is
+// needed between lines, e.g. being inside an XML comment
+type highlighter struct {
+	syntax Syntax
+
+	// XML
+	inComment bool
+	inTag     bool
+
+	// YAML: the indentation of the key owning a block scalar, or -1 outside one
+	blockIndent int
+}
+
+func (h *highlighter) spans(line string) []span {
+	switch h.syntax {
+	case JsonSyntax:
+		return jsonSpans(line, 0)
+	case YamlSyntax:
+		return h.yamlSpans(line)
+	case XmlSyntax:
+		return h.xmlSpans(line)
+	case IniSyntax:
+		return iniSpans(line)
+	}
+
+	return nil
+}
+
+// jsonSpans finds the strings, keys, numbers and literals in a line of JSON, starting at from.
+// It is forgiving, so is also used for YAML and TOML flow values.
+func jsonSpans(line string, from int) []span {
+	spans := []span{}
+	for i := from; i < len(line); {
+		c := line[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := quotedEnd(line, i)
+			kind := tokenString
+			if strings.HasPrefix(strings.TrimLeft(line[end:], " \t"), ":") {
+				kind = tokenKey
+			}
+			spans = append(spans, span{i, end, kind})
+			i = end
+		case isDigit(c) || (c == '-' && i+1 < len(line) && isDigit(line[i+1])):
+			end := i + 1
+			for end < len(line) && strings.IndexByte("0123456789.eE+-_", line[end]) >= 0 {
+				end++
+			}
+			spans = append(spans, span{i, end, tokenNumber})
+			i = end
+		case isLetter(c):
+			end := i + 1
+			for end < len(line) && isLetter(line[end]) {
+				end++
+			}
+			switch line[i:end] {
+			case "true", "false":
+				spans = append(spans, span{i, end, tokenBool})
+			case "null":
+				spans = append(spans, span{i, end, tokenNull})
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+
+	return spans
+}
+
+func (h *highlighter) yamlSpans(line string) []span {
+	trimmed := strings.TrimLeft(line, " \t")
+	i := len(line) - len(trimmed)
+
+	// Lines of a block scalar are indented more than its key, and are all text
+	if h.blockIndent >= 0 {
+		if trimmed == "" {
+			return nil
+		}
+		if i > h.blockIndent {
+			return []span{{i, len(line), tokenString}}
+		}
+		h.blockIndent = -1
+	}
+
+	if strings.HasPrefix(trimmed, "#") {
+		return []span{{i, len(line), tokenComment}}
+	}
+	if trimmed == "---" || trimmed == "..." {
+		return []span{{i, len(line), tokenTag}}
+	}
+
+	indent := i
+	for strings.HasPrefix(line[i:], "- ") {
+		i += 2
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+	}
+
+	spans := []span{}
+	if colon, ok := yamlKeyEnd(line, i); ok {
+		spans = append(spans, span{i, colon, tokenKey})
+		i = colon + 1
+	}
+
+	value := strings.TrimSpace(line[i:])
+	if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+		h.blockIndent = indent
+		return spans
+	}
+
+	return append(spans, scalarSpans(line, i)...)
+}
+
+// yamlKeyEnd finds the colon ending a mapping key starting at i, if there is one
+func yamlKeyEnd(line string, i int) (int, bool) {
+	if i >= len(line) || line[i] == '{' || line[i] == '[' || line[i] == '#' {
+		return 0, false
+	}
+
+	end := i
+	if line[i] == '"' || line[i] == '\'' {
+		end = quotedEnd(line, i)
+		for end < len(line) && line[end] == ' ' {
+			end++
+		}
+		if end < len(line) && line[end] == ':' && (end+1 == len(line) || line[end+1] == ' ') {
+			return end, true
+		}
+		return 0, false
+	}
+
+	for ; end < len(line); end++ {
+		if line[end] == '#' && line[end-1] == ' ' {
+			return 0, false
+		}
+		if line[end] == ':' && (end+1 == len(line) || line[end+1] == ' ') {
+			return end, true
+		}
+	}
+
+	return 0, false
+}
+
+// scalarSpans highlights a YAML or INI value, and any comment after it, starting at i
+func scalarSpans(line string, i int) []span {
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	if i >= len(line) {
+		return nil
+	}
+
+	switch line[i] {
+	case '#', ';':
+		return []span{{i, len(line), tokenComment}}
+	case '{', '[':
+		return jsonSpans(line, i)
+	case '"', '\'':
+		end := quotedEnd(line, i)
+		return append([]span{{i, end, tokenString}}, scalarSpans(line, end)...)
+	}
+
+	end := len(line)
+	spans := []span{}
+	if comment := strings.Index(line[i:], " #"); comment >= 0 {
+		end = i + comment
+		spans = append(spans, span{end + 1, len(line), tokenComment})
+	}
+
+	value := strings.TrimRight(line[i:end], " \t")
+	kind := tokenString
+	if literal, ok := literalKind(value); ok {
+		kind = literal
+	} else if numberPattern.MatchString(value) && strings.ContainsAny(value, "0123456789") {
+		kind = tokenNumber
+	}
+
+	return append([]span{{i, i + len(value), kind}}, spans...)
+}
+
+func iniSpans(line string) []span {
+	trimmed := strings.TrimSpace(line)
+	i := len(line) - len(strings.TrimLeft(line, " \t"))
+
+	switch {
+	case trimmed == "":
+		return nil
+	case strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#"):
+		return []span{{i, len(line), tokenComment}}
+	case strings.HasPrefix(trimmed, "["):
+		return []span{{i, i + len(trimmed), tokenTag}}
+	}
+
+	equals := strings.Index(line, "=")
+	if equals < 0 {
+		return nil
+	}
+
+	key := strings.TrimRight(line[i:equals], " \t")
+	return append([]span{{i, i + len(key), tokenKey}}, scalarSpans(line, equals+1)...)
+}
+
+func (h *highlighter) xmlSpans(line string) []span {
+	spans := []span{}
+	for i := 0; i < len(line); {
+		switch {
+		case h.inComment:
+			end := strings.Index(line[i:], "-->")
+			if end < 0 {
+				return append(spans, span{i, len(line), tokenComment})
+			}
+			spans = append(spans, span{i, i + end + 3, tokenComment})
+			i += end + 3
+			h.inComment = false
+		case h.inTag:
+			c := line[i]
+			switch {
+			case c == '>':
+				spans = append(spans, span{i, i + 1, tokenTag})
+				h.inTag = false
+				i++
+			case (c == '/' || c == '?') && i+1 < len(line) && line[i+1] == '>':
+				spans = append(spans, span{i, i + 2, tokenTag})
+				h.inTag = false
+				i += 2
+			case c == '"' || c == '\'':
+				end := quotedEnd(line, i)
+				spans = append(spans, span{i, end, tokenString})
+				i = end
+			case isNameChar(c):
+				end := i + 1
+				for end < len(line) && isNameChar(line[end]) {
+					end++
+				}
+				spans = append(spans, span{i, end, tokenKey})
+				i = end
+			default:
+				i++
+			}
+		case strings.HasPrefix(line[i:], "<!--"):
+			h.inComment = true
+		case line[i] == '<':
+			end := i + 1
+			for end < len(line) && (isNameChar(line[end]) || strings.IndexByte("/?!", line[end]) >= 0) {
+				end++
+			}
+			spans = append(spans, span{i, end, tokenTag})
+			h.inTag = true
+			i = end
+		default:
+			next := strings.IndexByte(line[i:], '<')
+			if next < 0 {
+				return spans
+			}
+			i += next
+		}
+	}
+
+	return spans
+}
+
+// quotedEnd is the index just after the string quoted at i, or the end of the line if it isn't closed
+func quotedEnd(line string, i int) int {
+	quote := line[i]
+	for j := i + 1; j < len(line); j++ {
+		if line[j] == '\\' && quote == '"' {
+			j++
+			continue
+		}
+		if line[j] == quote {
+			return j + 1
+		}
+	}
+
+	return len(line)
+}
+
+func literalKind(word string) (tokenKind, bool) {
+	lower := strings.ToLower(word)
+	for _, w := range boolWords {
+		if lower == w {
+			return tokenBool, true
+		}
+	}
+	for _, w := range nullWords {
+		if lower == w {
+			return tokenNull, true
+		}
+	}
+
+	return 0, false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNameChar(c byte) bool {
+	return isLetter(c) || isDigit(c) || strings.IndexByte("_-.:", c) >= 0
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pkg/errors"
+	"github.com/rivo/tview"
+
+	"urbanwizardry.com/kvv/internal/schema"
+)
+
+// jsonTreeInitialDepth is how many levels of the tree are expanded when a value is first shown
+const jsonTreeInitialDepth = 2
+
+// jsonNode is a parsed JSON value which, unlike unmarshalling to a map, keeps object keys in order
+type jsonNode struct {
+	path     string
+	name     string
+	value    any
+	children []*jsonNode
+	isArray  bool
+	isObject bool
+}
+
+// JsonTreeView shows a JSON value as a tree of collapsible objects and arrays
+type JsonTreeView struct {
+	// UI Layout
+	grid     *tview.Grid
+	tree     *tview.TreeView
+	pathLine *tview.TextView
+
+	// Events and Callbacks
+	copyFunc func(string)
+}
+
+var _ UIComponent = (*JsonTreeView)(nil)
+
+func NewJsonTreeView(
+	escapeFunc func(),
+	copyFunc func(string),
+) *JsonTreeView {
+	view := &JsonTreeView{
+		copyFunc: copyFunc,
+	}
+
+	view.pathLine = tview.NewTextView().SetTextColor(tcell.ColorBlue)
+	view.pathLine.SetBackgroundColor(tcell.ColorBlack)
+
+	view.tree = tview.NewTreeView().
+		SetGraphicsColor(tcell.ColorGray).
+		SetChangedFunc(view.nodeChanged).
+		SetSelectedFunc(func(node *tview.TreeNode) {
+			node.SetExpanded(!node.IsExpanded())
+		})
+
+	view.tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			escapeFunc()
+			return nil
+		case tcell.KeyLeft:
+			view.collapseOrParent()
+			return nil
+		case tcell.KeyRight:
+			if node := view.tree.GetCurrentNode(); node != nil {
+				node.Expand()
+			}
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'y':
+			// Copy the JSONPath of the selected node
+			if node := view.tree.GetCurrentNode(); node != nil {
+				if path, ok := node.GetReference().(string); ok {
+					view.copyFunc(path)
+				}
+			}
+			return nil
+		case 'E':
+			view.tree.GetRoot().ExpandAll()
+			return nil
+		case 'C':
+			view.tree.GetRoot().CollapseAll().Expand()
+			return nil
+		}
+
+		return event
+	})
+
+	view.tree.
+		SetBorderPadding(1, 1, 1, 1).
+		SetBorder(true).
+		SetTitle("Formatting: JSON Tree").
+		SetFocusFunc(func() {
+			view.tree.SetBorderColor(tcell.ColorBlue)
+		}).
+		SetBlurFunc(func() {
+			view.tree.SetBorderColor(tcell.ColorWhite)
+		})
+
+	view.grid = tview.NewGrid().
+		SetRows(0, 1).
+		AddItem(view.tree, 0, 0, 1, 1, 0, 0, false).
+		AddItem(view.pathLine, 1, 0, 1, 1, 0, 0, false)
+
+	return view
+}
+
+func (jt *JsonTreeView) GetPrimitive() tview.Primitive {
+	return jt.grid
+}
+
+// setValue rebuilds the tree for a new JSON value
+func (jt *JsonTreeView) setValue(value string) {
+	parsed, err := parseJsonTree([]byte(value))
+	if err != nil {
+		root := tview.NewTreeNode(tview.Escape(fmt.Sprintf("Not valid JSON: %s", err))).
+			SetColor(tcell.ColorRed)
+		jt.tree.SetRoot(root).SetCurrentNode(root)
+		jt.pathLine.SetText("")
+		return
+	}
+
+	root := buildTreeNode(parsed, 0)
+	jt.tree.SetRoot(root).SetCurrentNode(root)
+	jt.nodeChanged(root)
+}
+
+func (jt *JsonTreeView) nodeChanged(node *tview.TreeNode) {
+	if path, ok := node.GetReference().(string); ok {
+		jt.pathLine.SetText(fmt.Sprintf("%s  (y to copy)", path))
+	}
+}
+
+// collapseOrParent collapses the selected node, or if it already is collapsed, moves up to its parent
+func (jt *JsonTreeView) collapseOrParent() {
+	node := jt.tree.GetCurrentNode()
+	if node == nil {
+		return
+	}
+
+	if node.IsExpanded() && len(node.GetChildren()) > 0 {
+		node.Collapse()
+		return
+	}
+
+	path := jt.tree.GetPath(node)
+	if len(path) > 1 {
+		jt.tree.SetCurrentNode(path[len(path)-2])
+		jt.nodeChanged(path[len(path)-2])
+	}
+}
+
+func buildTreeNode(n *jsonNode, depth int) *tview.TreeNode {
+	var text string
+	var color tcell.Color
+
+	switch {
+	case n.isObject:
+		text = fmt.Sprintf("%s {%d}", n.name, len(n.children))
+		color = tcell.ColorBlue
+	case n.isArray:
+		text = fmt.Sprintf("%s [%d]", n.name, len(n.children))
+		color = tcell.ColorBlue
+	default:
+		scalar, _ := json.Marshal(n.value)
+		text = fmt.Sprintf("%s: %s", n.name, scalar)
+		color = tcell.ColorAntiqueWhite
+	}
+
+	node := tview.NewTreeNode(tview.Escape(text)).
+		SetColor(color).
+		SetReference(n.path).
+		SetExpanded(depth < jsonTreeInitialDepth)
+
+	for _, child := range n.children {
+		node.AddChild(buildTreeNode(child, depth+1))
+	}
+
+	return node
+}
+
+// parseJsonTree parses a single JSON value, keeping object keys in their original order
+func parseJsonTree(data []byte) (*jsonNode, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	root, err := parseJsonNode(decoder, "$", "$")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+
+	return root, nil
+}
+
+func parseJsonNode(decoder *json.Decoder, path string, name string) (*jsonNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &jsonNode{
+		path: path,
+		name: name,
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		node.value = token
+		return node, nil
+	}
+
+	switch delim {
+	case '{':
+		node.isObject = true
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyToken.(string)
+
+			child, err := parseJsonNode(decoder, schema.JSONPathChild(path, key), key)
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		}
+	case '[':
+		node.isArray = true
+		for i := 0; decoder.More(); i++ {
+			child, err := parseJsonNode(decoder, fmt.Sprintf("%s[%d]", path, i), fmt.Sprintf("[%d]", i))
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		}
+	}
+
+	// Consume the closing delimiter
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
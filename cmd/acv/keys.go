@@ -1,23 +1,71 @@
 package main
 
 import (
+	"fmt"
+	"maps"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
+const (
+	lockGlyph = "🔒"
+	markGlyph = "●"
+)
+
+// Change is how a setting was seen to change while watching
+type Change int
+
+const (
+	Added Change = iota + 1
+	Changed
+	Removed
+)
+
+// changeGlyphs are shown in the mark column of changed settings, when they aren't marked
+var changeGlyphs = map[Change]string{
+	Added:   "+",
+	Changed: "~",
+	Removed: "-",
+}
+
+// Columns of the keys list
+const (
+	MarkColumn = iota
+	LockColumn
+	KeyColumn
+	LabelColumn
+)
+
 type KeysManager struct {
 	grid                 *tview.Grid
 	keysBox              *tview.Grid
 	keys                 *tview.Table
 	settingSearchManager *SearchManager
-	keySelectedFunc      func(string)
+	keySelectedFunc      func(azappconfig.Setting)
+	cursorMovedFunc      func([]azappconfig.Setting)
+
+	// Internal state
+
+	// marked holds the IDs of settings marked for bulk actions, and markAnchor
+	// is the row last marked, which is where range marking starts from
+	marked     map[string]bool
+	markAnchor int
+	title      string
+
+	// changes holds the IDs of settings seen to change while watching
+	changes map[string]Change
 }
 
 func NewKeysManager(
-	keySelectedFunc func(string),
+	keySelectedFunc func(azappconfig.Setting),
+	cursorMovedFunc func([]azappconfig.Setting),
 ) *KeysManager {
 	manager := KeysManager{
 		keySelectedFunc: keySelectedFunc,
+		cursorMovedFunc: cursorMovedFunc,
+		marked:          map[string]bool{},
 	}
 
 	manager.grid = tview.NewGrid()
@@ -35,25 +83,18 @@ func NewKeysManager(
 		SetBorders(false).
 		SetSelectable(true, false).
 		Select(0, 0).
-		SetSelectedFunc(manager.settingSelected)
+		SetSelectedFunc(manager.settingSelected).
+		SetSelectionChangedFunc(manager.cursorMoved)
 
 	// Set things that chain as *tview.Box
 	manager.keys.SetFocusFunc(func() {
-		style := tcell.Style{}.Foreground(tcell.ColorBlue).Bold(true).Background(tcell.ColorBlack)
-		for row := range manager.keys.GetRowCount() {
-			for col := range manager.keys.GetColumnCount() {
-				manager.keys.GetCell(row, col).SetStyle(style)
-			}
-		}
+		manager.applyStyles(true)
 	}).SetBlurFunc(func() {
-		style := tcell.Style{}.Foreground(tcell.ColorAntiqueWhite).Bold(false).Background(tcell.ColorBlack)
-		for row := range manager.keys.GetRowCount() {
-			for col := range manager.keys.GetColumnCount() {
-				manager.keys.GetCell(row, col).SetStyle(style)
-			}
-		}
+		manager.applyStyles(false)
 	}).SetBorderPadding(1, 1, 1, 1)
 
+	manager.keys.SetInputCapture(manager.onInput)
+
 	manager.keysBox = tview.NewGrid()
 	manager.keysBox.SetBorder(true)
 	manager.keysBox.AddItem(manager.keys, 0, 0, 1, 1, 0, 0, false)
@@ -62,6 +103,7 @@ func NewKeysManager(
 
 	// Setting Search Bar
 	manager.settingSearchManager = NewSearchManager(
+		"Search: ",
 		func(p tview.Primitive) {
 			app.SetFocus(p)
 		},
@@ -82,22 +124,239 @@ func (km *KeysManager) GetPrimitive() tview.Primitive {
 	return km.grid
 }
 
-func (km *KeysManager) updateKeys(settings []string) {
+// updateKeys fills the list with one row per setting, showing its lock state, key and label
+func (km *KeysManager) updateKeys(settings []azappconfig.Setting) {
 	km.keys.Clear()
 	for row, setting := range settings {
-		km.keys.SetCell(row, 0, tview.NewTableCell(setting))
+		lock := ""
+		if derefOr(setting.IsReadOnly, false) {
+			lock = lockGlyph
+		}
+
+		km.keys.SetCell(row, MarkColumn, tview.NewTableCell(""))
+		km.keys.SetCell(row, LockColumn, tview.NewTableCell(lock))
+		km.keys.SetCell(row, KeyColumn, tview.NewTableCell(*setting.Key).SetExpansion(1).SetReference(setting))
+		km.keys.SetCell(row, LabelColumn, tview.NewTableCell(labelText(setting.Label)))
 	}
+
+	km.applyStyles(km.keys.HasFocus())
+}
+
+// applyStyles styles every row according to focus, and whether it is marked
+func (km *KeysManager) applyStyles(focused bool) {
+	marks := 0
+	for row := range km.keys.GetRowCount() {
+		style := UIStyles.TableCellBlur
+		if focused {
+			style = UIStyles.TableCellFocus
+		}
+
+		mark := ""
+		if setting := km.settingAt(row); setting != nil && km.marked[settingID(*setting)] {
+			style = UIStyles.TableCellMarked
+			mark = markGlyph
+			marks++
+		} else if setting != nil && km.changes[settingID(*setting)] != 0 {
+			change := km.changes[settingID(*setting)]
+			style = map[Change]tcell.Style{
+				Added:   UIStyles.TableCellAdded,
+				Changed: UIStyles.TableCellChanged,
+				Removed: UIStyles.TableCellRemoved,
+			}[change]
+			mark = changeGlyphs[change]
+		}
+
+		km.keys.GetCell(row, MarkColumn).SetText(mark)
+		for col := range km.keys.GetColumnCount() {
+			km.keys.GetCell(row, col).SetStyle(style)
+		}
+	}
+
+	km.updateTitle(marks)
+}
+
+func (km *KeysManager) onInput(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Rune() {
+	case ' ':
+		// Toggle the mark on this row, and move on to the next
+		row, _ := km.keys.GetSelection()
+		km.toggleMark(row)
+		km.markAnchor = row
+		if row+1 < km.keys.GetRowCount() {
+			km.keys.Select(row+1, 0)
+		}
+		km.applyStyles(true)
+		return nil
+	case 'v':
+		// Mark every row from the last one marked to this one
+		row, _ := km.keys.GetSelection()
+		from, to := min(row, km.markAnchor), max(row, km.markAnchor)
+		for r := from; r <= to; r++ {
+			if setting := km.settingAt(r); setting != nil {
+				km.marked[settingID(*setting)] = true
+			}
+		}
+		km.applyStyles(true)
+		return nil
+	case 'a':
+		// Mark every listed row, or clear the marks if they already are
+		km.toggleMarkAll()
+		km.applyStyles(true)
+		return nil
+	}
+
+	return event
+}
+
+func (km *KeysManager) toggleMark(row int) {
+	setting := km.settingAt(row)
+	if setting == nil {
+		return
+	}
+
+	id := settingID(*setting)
+	if km.marked[id] {
+		delete(km.marked, id)
+	} else {
+		km.marked[id] = true
+	}
+}
+
+func (km *KeysManager) toggleMarkAll() {
+	allMarked := true
+	for row := range km.keys.GetRowCount() {
+		if setting := km.settingAt(row); setting != nil && !km.marked[settingID(*setting)] {
+			allMarked = false
+			break
+		}
+	}
+
+	km.marked = map[string]bool{}
+	if allMarked {
+		return
+	}
+
+	for row := range km.keys.GetRowCount() {
+		if setting := km.settingAt(row); setting != nil {
+			km.marked[settingID(*setting)] = true
+		}
+	}
+}
+
+// markedSettings returns the listed settings which are marked, in list order
+func (km *KeysManager) markedSettings() []azappconfig.Setting {
+	marked := []azappconfig.Setting{}
+	for row := range km.keys.GetRowCount() {
+		if setting := km.settingAt(row); setting != nil && km.marked[settingID(*setting)] {
+			marked = append(marked, *setting)
+		}
+	}
+
+	return marked
 }
 
 func (km *KeysManager) settingSelected(row int, col int) {
-	settingCell := km.keys.GetCell(row, col)
-	if settingCell == nil {
+	setting := km.settingAt(row)
+	if setting == nil {
 		return
 	}
 
-	km.keySelectedFunc(settingCell.Text)
+	km.keySelectedFunc(*setting)
+}
+
+// cursorMoved passes on the settings around the cursor, nearest first, as they are likely
+// to be selected next
+func (km *KeysManager) cursorMoved(row int, col int) {
+	nearby := []azappconfig.Setting{}
+	add := func(r int) {
+		if setting := km.settingAt(r); setting != nil {
+			nearby = append(nearby, *setting)
+		}
+	}
+
+	add(row)
+	for distance := 1; distance <= prefetchRadius; distance++ {
+		add(row + distance)
+		add(row - distance)
+	}
+
+	km.cursorMovedFunc(nearby)
+}
+
+// selectedSetting returns the setting on the currently selected row, or nil if the list is empty
+func (km *KeysManager) selectedSetting() *azappconfig.Setting {
+	row, _ := km.keys.GetSelection()
+	return km.settingAt(row)
+}
+
+func (km *KeysManager) settingAt(row int) *azappconfig.Setting {
+	settingCell := km.keys.GetCell(row, KeyColumn)
+	if settingCell == nil {
+		return nil
+	}
+
+	setting, ok := settingCell.GetReference().(azappconfig.Setting)
+	if !ok {
+		return nil
+	}
+
+	return &setting
+}
+
+// KeysView is where the keys list was left, so that it can be restored
+type KeysView struct {
+	search string
+	marked map[string]bool
+	row    int
+	offset int
+}
+
+// saveView records the search, marks, selection and scroll position of the keys list
+func (km *KeysManager) saveView() KeysView {
+	row, _ := km.keys.GetSelection()
+	offset, _ := km.keys.GetOffset()
+
+	return KeysView{
+		search: km.settingSearchManager.searchBox.GetText(),
+		marked: maps.Clone(km.marked),
+		row:    row,
+		offset: offset,
+	}
+}
+
+// restoreView puts the keys list back as it was, once its keys have been updated
+func (km *KeysManager) restoreView(view KeysView) {
+	km.settingSearchManager.searchBox.SetText(view.search)
+	km.marked = maps.Clone(view.marked)
+	if km.marked == nil {
+		km.marked = map[string]bool{}
+	}
+
+	km.keys.Select(min(view.row, max(km.keys.GetRowCount()-1, 0)), 0)
+	km.keys.SetOffset(view.offset, 0)
+	km.applyStyles(km.keys.HasFocus())
+}
+
+// setChanges highlights settings which have changed, replacing any highlighted before
+func (km *KeysManager) setChanges(changes map[string]Change) {
+	km.changes = changes
+	km.applyStyles(km.keys.HasFocus())
 }
 
 func (km *KeysManager) SetTitle(title string) {
+	km.title = title
+	km.updateTitle(len(km.markedSettings()))
+}
+
+func (km *KeysManager) updateTitle(marks int) {
+	if marks == 0 {
+		km.keysBox.SetTitle(km.title)
+		return
+	}
+
+	title := fmt.Sprintf("[yellow]%d marked[-]", marks)
+	if km.title != "" {
+		title = fmt.Sprintf("%s %s", km.title, title)
+	}
 	km.keysBox.SetTitle(title)
 }
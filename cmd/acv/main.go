@@ -1,31 +1,60 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
 	"github.com/atotto/clipboard"
 	"github.com/gdamore/tcell/v2"
 	"github.com/pkg/errors"
 	"github.com/rivo/tview"
+
+	"urbanwizardry.com/kvv/internal/auth"
+	"urbanwizardry.com/kvv/internal/cache"
+	"urbanwizardry.com/kvv/internal/config"
+	"urbanwizardry.com/kvv/internal/recording"
+	"urbanwizardry.com/kvv/internal/schema"
 )
 
 var (
 	settings      []azappconfig.Setting
 	app           *tview.Application
+	pages         *tview.Pages
 	header        *Header
 	client        *azappconfig.Client
-	cred          *azidentity.DefaultAzureCredential
+	credentials   *auth.Credentials
 	keysManager   *KeysManager
 	valuesManager *ValuesManager
+	statusBar     *StatusBar
+	timeline      *TimelineManager
+	confirmDialog *ConfirmDialog
+	promptDialog  *PromptDialog
+	choiceDialog  *ChoiceDialog
+	validator     *schema.Validator
+
+	// mainGrid lays out the main page, with the current tab's keys and values
+	mainGrid *tview.Grid
 
 	viewMode ValueDisplayMode
+
+	// asOf is the point in time being viewed, nil when viewing live settings
+	asOf *time.Time
+
+	// acvConfig is the config file, with any server from the command line added
+	acvConfig config.Config
+
+	// configServers are all of the servers that can be selected, and currentServer
+	// is the one client is connected to
+	configServers []string
+	currentServer string
 )
 
 const (
@@ -39,88 +68,178 @@ Version 0.1.0
 `
 )
 
-type acvConfig struct {
-	ConfigServers []string `yaml:"servers"`
-}
+// Names of the pages that can be shown
+const (
+	MainPage     = "main"
+	TimelinePage = "timeline"
+	ConfirmPage  = "confirm"
+	PromptPage   = "prompt"
+	ChoicePage   = "choice"
+)
+
+// connectionStringEnv can hold a connection string to use instead of --connection-string
+const connectionStringEnv = "ACV_CONNECTION_STRING"
 
 func main() {
-	configServers := []string{}
-	if len(os.Args) > 1 {
-		cliServer := os.Args[1]
-		// This validation is a little weak
-		if !strings.HasPrefix(cliServer, "https://") {
-			cliServer = fmt.Sprintf("https://%s", cliServer)
-		}
+	connectionString := flag.String(
+		"connection-string",
+		"",
+		fmt.Sprintf("authenticate with an access key connection string rather than Entra ID (or set %s)", connectionStringEnv),
+	)
+	flag.BoolVar(&offline, "offline", false, "only show settings and revisions cached from earlier, without fetching anything")
+	record := flag.String("record", "", "save every request and response to this directory, without authentication headers")
+	replay := flag.String("replay", "", "answer requests with the responses saved to this directory by --record, instead of servers")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: acv [--offline] [--record dir | --replay dir] [--connection-string Endpoint=...;Id=...;Secret=...] [server]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-		configServers = append(configServers, cliServer)
+	var err error
+	acvConfig, err = config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	validator, err = schema.NewValidator(acvConfig.Schemas)
+	if err != nil {
+		log.Fatal(err)
 	}
 
+	// A server given on the command line is opened first, then any others from the config file
+	// The environment's connection string is only used for the server it's for, or when there
+	// isn't one given
+	if env := os.Getenv(connectionStringEnv); *connectionString == "" && env != "" {
+		if flag.NArg() == 0 || config.IsConnectionStringFor(env, config.ServerURL(flag.Arg(0))) {
+			*connectionString = env
+		}
+	}
+	if flag.NArg() > 0 || *connectionString != "" {
+		server := config.Server{ConnectionString: *connectionString}
+		if flag.NArg() > 0 {
+			server.URL = config.ServerURL(flag.Arg(0))
+		} else if server.URL, err = config.ConnectionStringEndpoint(*connectionString); err != nil {
+			log.Fatal(err)
+		}
+		if err := acvConfig.UseServer(server); err != nil {
+			log.Fatal(err)
+		}
+	}
+	configServers = arraymap(acvConfig.Servers, func(s config.Server) string {
+		return s.URL
+	})
+
 	if len(configServers) == 0 {
 		log.Fatal("No app configurations to open, exiting")
 	}
 
-	var err error
-	cred, err = azidentity.NewDefaultAzureCredential(nil)
-	if err != nil {
-		log.Fatalf("failed to obtain a credential: %v", err)
+	credentials = auth.NewCredentials(showDeviceCodePrompt, acvConfig.Retry, showThrottled)
+
+	switch {
+	case (*record != "" || *replay != "") && offline:
+		log.Fatal("--offline can't be used with --record or --replay")
+	case *record != "" && *replay != "":
+		log.Fatal("--record and --replay can't be used together")
+	case *record != "":
+		recorder, err := recording.NewRecorder(*record)
+		if err != nil {
+			log.Fatal(err)
+		}
+		credentials.UseTransport(recorder)
+	case *replay != "":
+		player, err := recording.NewPlayer(*replay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		credentials.Replay(player)
+	}
+
+	// Without a cache everything is fetched, which is only a problem when that isn't possible.
+	// Recordings don't use it, so that the same requests are made each time.
+	if *record == "" && *replay == "" {
+		settingsCache, err = cache.New()
+		if err != nil && offline {
+			log.Fatal(err)
+		}
 	}
 
 	// Top stuff
 	header = NewHeader(
-		configServers,
+		acvConfig.Servers,
 		func() {
 			app.SetFocus(keysManager.keys)
 		},
 		func(server string) {
-			connect(server)
-			fetchSettings("*")
-			updateKeysList()
+			if err := switchServer(server); err != nil {
+				statusBar.SetError(err)
+				return
+			}
+			app.SetFocus(keysManager.keys)
+		},
+		func(t *time.Time) {
+			timeTravel(t)
 			app.SetFocus(keysManager.keys)
 		},
+		func(err error) {
+			statusBar.SetError(err)
+		},
 	)
 
-	// Navigable list of setting keys
-	keysManager = NewKeysManager(func(s string) {
-		revisions, err := getSettingRevisions(s, client)
-		if err != nil {
-			// chill for now
-			return
-		}
-
-		if viewMode == Standard {
-			getValuesManager().setPrimaryRevisions(s, revisions)
-		} else {
-			getValuesManager().setDiffRightRevisions(s, revisions)
-		}
+	statusBar = NewStatusBar()
 
-	})
+	// The first tab's keys and values
+	keysManager = newKeysManager()
+	valuesManager = newValuesManager()
+	tabs = []*Tab{{}}
 
-	// Display of revision history and values
-	valuesManager = NewValuesManager(
+	// Store-wide revision history
+	timeline = NewTimelineManager(
+		func(keyFilter string, labelFilter string, until *time.Time) ([]azappconfig.Setting, error) {
+			if offline {
+				return nil, errOffline
+			}
+			return listRevisions(eventLoop, client, keyFilter, labelFilter, until)
+		},
 		func() {
-			// Escaping out of the revisions dropdown, restore focus to the keys list
+			pages.SwitchToPage(MainPage)
 			app.SetFocus(keysManager.keys)
 		},
+		func(err error) {
+			statusBar.SetError(err)
+		},
 		func(p tview.Primitive) {
 			app.SetFocus(p)
 		},
 	)
 
-	valuesManager.setRenderType(Plain)
-
 	// Page layout
-	pageGrid := tview.NewGrid().
-		SetRows(8, 3, 0, 3).
+	mainGrid = tview.NewGrid().
+		SetRows(9, 3, 0, 3).
 		SetColumns(-3, -4).
 		SetBorders(false).
-		AddItem(header.GetPrimitive(), 0, 0, 1, 2, 0, 0, false).
-		AddItem(keysManager.GetPrimitive(), 1, 0, 3, 1, 0, 0, true).
-		AddItem(valuesManager.GetPrimitive(), 1, 1, 3, 1, 0, 0, false)
+		AddItem(header.GetPrimitive(), 0, 0, 1, 2, 0, 0, false)
+	addWorkspace()
 
-	pageGrid.
+	mainGrid.
 		SetBorderStyle(tcell.Style{}.Bold(true)).SetBackgroundColor(tcell.ColorBlack)
 
-	app = tview.NewApplication().SetRoot(pageGrid, true)
+	confirmDialog = NewConfirmDialog()
+	promptDialog = NewPromptDialog()
+	choiceDialog = NewChoiceDialog()
+
+	pages = tview.NewPages().
+		AddPage(MainPage, mainGrid, true, true).
+		AddPage(TimelinePage, timeline.GetPrimitive(), true, false).
+		AddPage(ConfirmPage, confirmDialog.GetPrimitive(), true, false).
+		AddPage(PromptPage, promptDialog.GetPrimitive(), true, false).
+		AddPage(ChoicePage, choiceDialog.GetPrimitive(), true, false)
+
+	root := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(pages, 0, 1, true).
+		AddItem(statusBar.GetPrimitive(), 1, 0, false)
+
+	app = tview.NewApplication().SetRoot(root, true)
 
 	app.SetInputCapture(mainInputCapture)
 
@@ -130,32 +249,166 @@ func main() {
 	// before the UI is ready for them
 	header.SelectFirstServer()
 
+	// Keep the age of the keys list up to date
+	go func() {
+		for range time.Tick(refreshAgeInterval) {
+			app.QueueUpdateDraw(updateKeysTitle)
+		}
+	}()
+
 	if err := app.Run(); err != nil {
 		panic(err)
 	}
 }
 
+// newKeysManager creates a navigable list of setting keys, for a tab
+func newKeysManager() *KeysManager {
+	return NewKeysManager(func(s azappconfig.Setting) {
+		revisions, err := getSettingRevisions(s, client, asOf)
+		if err != nil {
+			statusBar.SetError(err)
+			return
+		}
+
+		if viewMode == Standard {
+			getValuesManager().setPrimaryRevisions(settingDisplayName(s), revisions)
+		} else {
+			getValuesManager().setDiffRightRevisions(settingDisplayName(s), revisions)
+		}
+
+	}, func(nearby []azappconfig.Setting) {
+		// Only live revisions are kept, and they're only looked for with the cache, which isn't
+		// used by recordings so that they don't depend on where the cursor went
+		if client == nil || asOf != nil || offline || settingsCache == nil {
+			return
+		}
+		prefetcher.Prefetch(client, currentServer, nearby)
+	})
+}
+
+// newValuesManager creates a display of revision history and values, for a tab
+func newValuesManager() *ValuesManager {
+	manager := NewValuesManager(
+		func() {
+			// Escaping out of the revisions dropdown, restore focus to the keys list
+			app.SetFocus(keysManager.keys)
+		},
+		func(p tview.Primitive) {
+			app.SetFocus(p)
+		},
+		func(s string) {
+			clipboard.WriteAll(s)
+			statusBar.SetMessage(fmt.Sprintf("Copied %s", s))
+		},
+	)
+
+	manager.setRenderType(Plain)
+	manager.setValidator(validator)
+	return manager
+}
+
 func mainInputCapture(event *tcell.EventKey) *tcell.EventKey {
 	// Block Ctrl-C to exit
 	if event.Key() == tcell.KeyCtrlC {
 		return nil
 	}
 
+	if page, _ := pages.GetFrontPage(); page != MainPage {
+		// Other pages handle their own keystrokes
+		return event
+	}
+
 	if keysManager.settingSearchManager.searchType == StringSearch || valuesManager.valueSearchManager.searchType == StringSearch {
 		// We are actively searching, don't steal the keystrokes
 		return event
 	}
 
+	if _, ok := app.GetFocus().(*tview.InputField); ok {
+		// Typing into some other input, e.g. the time travel field
+		return event
+	}
+
 	switch event.Rune() {
 	case 'c':
 		copyValue()
 		return nil
+	case 'o':
+		openInEditor()
+		return nil
+	case 'O':
+		openInPager()
+		return nil
 	case 's':
 		app.SetFocus(header.acDropdown)
 		return nil
+	case 't':
+		app.SetFocus(header.timeTravelInput)
+		return nil
+	case 'D':
+		// Diff the historical value against the same setting as it is now
+		diffWithNow()
+		return nil
+	case 'T':
+		showTimeline()
+		return nil
+	case 'm':
+		valuesManager.toggleMetadata()
+		return nil
+	case 'e':
+		// Evaluate a jq query against the value
+		valuesManager.valueQueryManager.setSearching(StringSearch)
+		return nil
+	case 'l':
+		toggleLockSelected()
+		return nil
+	case 'L':
+		setReadOnlyBulk(true)
+		return nil
+	case 'U':
+		setReadOnlyBulk(false)
+		return nil
+	case 'x':
+		deleteSelected()
+		return nil
+	case 'X':
+		deleteBulk()
+		return nil
+	case 'b':
+		showBulkMenu()
+		return nil
+	case 'p':
+		promote()
+		return nil
+	case 'R':
+		renamePrefix()
+		return nil
+	case 'u':
+		undoDelete()
+		return nil
 	case 'q':
 		app.Stop()
 		return nil
+	case '+':
+		newTab()
+		return nil
+	case '-':
+		closeTab()
+		return nil
+	case '[':
+		stepTab(-1)
+		return nil
+	case ']':
+		stepTab(1)
+		return nil
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		switchTab(int(event.Rune() - '1'))
+		return nil
+	case 'f':
+		filterTab()
+		return nil
+	case 'w':
+		toggleWatch()
+		return nil
 	case '/':
 		// Search setting keys or setting value, depending which (if either) is focused
 		if app.GetFocus() == keysManager.keys {
@@ -179,16 +432,13 @@ func mainInputCapture(event *tcell.EventKey) *tcell.EventKey {
 
 		fetchSettings("*")
 		updateKeysList()
+		updateKeysTitle()
 		app.SetFocus(keysManager.keys)
 		return nil
 
 	case 'j':
-		// Toggle JSON rendering
-		if valuesManager.renderType != Json {
-			valuesManager.setRenderType(Json)
-		} else {
-			valuesManager.setRenderType(Plain)
-		}
+		// Cycle through value renderings
+		valuesManager.cycleRenderType()
 		valuesManager.updateValueBasedOnView()
 		return nil
 
@@ -213,36 +463,109 @@ func mainInputCapture(event *tcell.EventKey) *tcell.EventKey {
 	return event
 }
 
-func connect(serverUri string) {
-	var err error
+func connect(serverUri string) error {
 	// Establish a connection to the Key Vault client
-	client, err = azappconfig.NewClient(serverUri, cred, nil)
+	serverClient, err := newClient(serverUri)
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	client = serverClient
+	currentServer = serverUri
+	return nil
+}
+
+// newClient creates a client for any server, without making it the current one. Credentials
+// are created the first time a server needs them.
+func newClient(serverUri string) (*azappconfig.Client, error) {
+	return credentials.NewClient(acvConfig.Server(serverUri))
+}
+
+// showDeviceCodePrompt tells the user how to sign in with a device code, outside of the UI
+func showDeviceCodePrompt(message string) {
+	suspended := app.Suspend(func() {
+		fmt.Println(message)
+		fmt.Print("Press Enter once signed in to continue...")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+	})
+
+	if !suspended {
+		// The UI hasn't started yet, so the terminal is free
+		fmt.Println(message)
+	}
+}
+
+// eventLoop is the context of requests made on the event loop, which can't draw anything until
+// they are done
+var eventLoop = context.WithValue(context.Background(), eventLoopKey{}, true)
+
+type eventLoopKey struct{}
+
+// showThrottled tells the user that a server is throttling a request, and when it will be
+// retried. It is called on whichever goroutine made the request, before waiting to retry. The
+// status bar can be set from any goroutine, but a request made on the event loop has to draw it
+// there and then, as the event loop is blocked until the request is done.
+func showThrottled(ctx context.Context, host string, delay time.Duration) {
+	message := fmt.Sprintf("Throttled by %s, retrying", host)
+	if delay > 0 {
+		message += fmt.Sprintf(" in %s", max(delay.Round(time.Second), time.Second))
+	}
+
+	statusBar.SetWarning(message)
+	if ctx.Value(eventLoopKey{}) != nil {
+		app.ForceDraw()
+	} else {
+		go app.Draw()
 	}
 }
 
-// fetchSettings uses the server's filtering to fetch settings based on a filter string
+// fetchSettings uses the server's filtering to fetch settings based on a filter string. All of
+// the live settings are cached, and shown from the cache while checking it is up to date.
 func fetchSettings(keyFilter string) {
+	if client == nil {
+		// Not connected to any server yet
+		return
+	}
+
+	err := errOffline
+	if keyFilter == "*" && asOf == nil && settingsCache != nil {
+		err = fetchCachedSettings()
+	} else if !offline {
+		settings, err = listSettings(client, keyFilter, currentLabelFilter, asOf)
+		refreshed = time.Now()
+	}
+
+	if err != nil {
+		settings = nil
+		refreshed = time.Time{}
+		statusBar.SetError(err)
+	}
+}
+
+// listSettings fetches all settings matching the key and label filters
+func listSettings(client *azappconfig.Client, keyFilter string, labelFilter string, asOf *time.Time) ([]azappconfig.Setting, error) {
 	settingsPager := client.NewListSettingsPager(
 		azappconfig.SettingSelector{
-			KeyFilter:   to.Ptr(keyFilter),
-			LabelFilter: to.Ptr("*"),
-			Fields:      azappconfig.AllSettingFields(),
+			KeyFilter:      to.Ptr(keyFilter),
+			LabelFilter:    to.Ptr(labelFilter),
+			AcceptDateTime: asOf,
+			Fields:         azappconfig.AllSettingFields(),
 		},
 		nil,
 	)
 
-	settings = []azappconfig.Setting{}
+	settings := []azappconfig.Setting{}
 
 	for settingsPager.More() {
-		resp, err := settingsPager.NextPage(context.Background())
+		resp, err := settingsPager.NextPage(eventLoop)
 		if err != nil {
-			panic(errors.Wrap(err, "failed to get paged settigns"))
+			return nil, errors.Wrap(err, "failed to get paged settigns")
 		}
 
 		settings = append(settings, resp.Settings...)
 	}
+
+	return settings, nil
 }
 
 // findSettings iterates through the currently fetched settings looking for key name
@@ -260,20 +583,45 @@ func findSettings(searchString string) {
 }
 
 func updateKeysList() {
-	keys := arraymap(settings, func(s azappconfig.Setting) string { return *s.Key })
-	keysManager.updateKeys(keys)
+	keysManager.updateKeys(settings)
+}
+
+// replaceSettings swaps in newly fetched settings, keeping the keys list as it was, including
+// any search of it
+func replaceSettings(updated []azappconfig.Setting) {
+	view := keysManager.saveView()
+	settings = updated
+	if view.search != "" {
+		findSettings(view.search)
+	}
+	updateKeysList()
+	keysManager.restoreView(view)
 }
 
 func getValuesManager() *ValuesManager {
 	return valuesManager
 }
 
-func getSettingRevisions(settingName string, client *azappconfig.Client) ([]azappconfig.Setting, error) {
+// getSettingRevisions fetches the revisions of a setting's key and label. If asOf is given,
+// only revisions that existed at that point in time are returned
+func getSettingRevisions(setting azappconfig.Setting, client *azappconfig.Client, asOf *time.Time) ([]azappconfig.Setting, error) {
+	if asOf == nil && settingsCache != nil {
+		return getCachedRevisions(setting, client)
+	} else if offline {
+		return nil, errOffline
+	}
+
+	return listRevisions(eventLoop, client, escapeFilter(*setting.Key), labelFilter(setting.Label), asOf)
+}
+
+// listRevisions fetches the revisions of all settings matching the key and label filters
+func listRevisions(ctx context.Context, client *azappconfig.Client, keyFilter string, labelFilter string, asOf *time.Time) ([]azappconfig.Setting, error) {
 	pager := client.NewListRevisionsPager(
 		azappconfig.SettingSelector{
-			KeyFilter:   to.Ptr(settingName),
-			LabelFilter: to.Ptr("*"),
-			Fields:      azappconfig.AllSettingFields(),
+			KeyFilter:      to.Ptr(keyFilter),
+			LabelFilter:    to.Ptr(labelFilter),
+			AcceptDateTime: asOf,
+			Fields:         azappconfig.AllSettingFields(),
 		},
 		nil,
 	)
@@ -281,7 +629,7 @@ func getSettingRevisions(settingName string, client *azappconfig.Client) ([]azap
 	revisions := []azappconfig.Setting{}
 
 	for pager.More() {
-		resp, err := pager.NextPage(context.Background())
+		resp, err := pager.NextPage(ctx)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to get paged secret versions")
 		}
@@ -293,16 +641,106 @@ func getSettingRevisions(settingName string, client *azappconfig.Client) ([]azap
 }
 
 func copyValue() {
-	clipboard.WriteAll(valuesManager.valueTextView.GetText(false))
+	clipboard.WriteAll(valuesManager.plainValue())
 }
 
 func setDisplayMode(mode ValueDisplayMode) {
 	viewMode = mode
 	valuesManager.SetDisplayMode(mode)
 
-	if mode == Diff {
-		keysManager.SetTitle("Selecting For Diff Value (green)")
+	updateKeysTitle()
+}
+
+// updateKeysTitle describes what the keys list is showing, and what selecting from it will do
+func updateKeysTitle() {
+	title := ""
+	if asOf != nil {
+		title = fmt.Sprintf("[red]Historical: %s[-]", asOf.Format(time.RFC822))
+	}
+
+	if viewMode == Diff {
+		if title != "" {
+			title += " "
+		}
+		title += "Selecting For Diff Value (green)"
+	}
+
+	if offline {
+		if title != "" {
+			title += " "
+		}
+		title += "[yellow]Offline[-]"
+	}
+
+	if watching {
+		if title != "" {
+			title += " "
+		}
+		title += "[green]Watching[-]"
+	}
+
+	if age := refreshAge(); age != "" {
+		if title != "" {
+			title += " "
+		}
+		title += fmt.Sprintf("[gray]%s[-]", age)
+	}
+
+	keysManager.SetTitle(title)
+	updateTabBar()
+}
+
+// timeTravel reloads the keys list and values as they were at a point in time,
+// or as they are now if t is nil
+func timeTravel(t *time.Time) {
+	asOf = t
+	header.SetHistorical(asOf)
+
+	if viewMode == Diff {
+		setDisplayMode(Standard)
+	}
+	valuesManager.reset()
+	keysManager.settingSearchManager.Reset()
+
+	fetchSettings("*")
+	updateKeysList()
+	updateKeysTitle()
+
+	if asOf == nil {
+		statusBar.SetMessage("Viewing live settings")
 	} else {
-		keysManager.SetTitle("")
+		statusBar.SetMessage(fmt.Sprintf("Viewing settings as they were at %s, press D to diff a value with now", asOf.Format(time.RFC1123)))
+	}
+}
+
+// showTimeline switches to the store-wide timeline of changes, fetching it the first time
+func showTimeline() {
+	if timeline.entries.GetRowCount() == 0 {
+		timeline.Refresh()
+	}
+	pages.SwitchToPage(TimelinePage)
+	app.SetFocus(timeline.entries)
+}
+
+// diffWithNow puts the values view into diff mode, with the historical value on the left and
+// the current revisions of the same setting on the right
+func diffWithNow() {
+	if asOf == nil {
+		statusBar.SetMessage("Diff with now is only available when time travelling")
+		return
 	}
+
+	historical := valuesManager.primaryRevisionSelector.GetCurrentRevision()
+	if historical == nil {
+		return
+	}
+
+	revisions, err := getSettingRevisions(*historical, client, nil)
+	if err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	setDisplayMode(Diff)
+	valuesManager.setDiffRightRevisions(settingDisplayName(*historical), revisions)
 }
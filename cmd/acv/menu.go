@@ -22,15 +22,53 @@ func NewShortcutMenu() ShortcutMenu {
 		map[rune]string{
 			's': "Change config server",
 			'q': "Quit ACV",
+			't': "Time travel (as of)",
+			'e': "Query value with jq",
 		},
 		map[rune]string{
 			'/': "Search (keys or value)",
+			'm': "Toggle metadata panel",
 			'r': "Reload keys list",
+			'T': "Timeline of changes",
 		},
 		map[rune]string{
-			'j': "Toggle JSON prettyprint",
+			'j': "Cycle value rendering",
 			'd': "Toggle diff mode",
 			'c': "Copy selected value",
+			'D': "Diff historical with now",
+		},
+		map[rune]string{
+			'p': "Promote to label/store",
+			'R': "Rename key prefix",
+			'y': "Copy JSON path (tree)",
+			'n': "Next match (N: previous)",
+		},
+		map[rune]string{
+			'l': "Lock/unlock selected",
+			'L': "Lock marked/listed",
+			'U': "Unlock marked/listed",
+		},
+		map[rune]string{
+			'x': "Delete selected",
+			'X': "Delete marked/listed",
+			'u': "Undo last delete",
+			'b': "Bulk actions",
+		},
+		map[rune]string{
+			' ': "Mark/unmark key",
+			'v': "Mark range",
+			'a': "Mark all listed",
+		},
+		map[rune]string{
+			'o': "Open value in $EDITOR",
+			'O': "Open value in $PAGER",
+			'w': "Watch for changes",
+		},
+		map[rune]string{
+			'+': "New tab (-: close)",
+			']': "Next tab ([: previous)",
+			'1': "Go to tab (1-9)",
+			'f': "Filter tab by label",
 		},
 	}
 
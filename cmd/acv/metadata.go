@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/rivo/tview"
+)
+
+// MetadataPanel shows everything about a setting revision other than its value
+type MetadataPanel struct {
+	textView *tview.TextView
+}
+
+var _ UIComponent = (*MetadataPanel)(nil)
+
+// metadataPanelHeight is enough for every line of metadataText, plus the border
+const metadataPanelHeight = 8
+
+func NewMetadataPanel() *MetadataPanel {
+	textView := tview.NewTextView().SetDynamicColors(true)
+	textView.
+		SetBorderPadding(0, 0, 1, 1).
+		SetBorder(true).
+		SetTitle("Metadata")
+
+	return &MetadataPanel{
+		textView: textView,
+	}
+}
+
+func (mp *MetadataPanel) GetPrimitive() tview.Primitive {
+	return mp.textView
+}
+
+// showRevision displays the metadata of a single revision
+func (mp *MetadataPanel) showRevision(revision *azappconfig.Setting) {
+	if revision == nil {
+		mp.textView.SetText("")
+		return
+	}
+
+	mp.textView.SetText(tview.Escape(metadataText(*revision)))
+}
+
+// showDiff displays the metadata of two revisions, with the differences coloured as for values
+func (mp *MetadataPanel) showDiff(left *azappconfig.Setting, right *azappconfig.Setting) {
+	leftText, rightText := "", ""
+	if left != nil {
+		leftText = metadataText(*left)
+	}
+	if right != nil {
+		rightText = metadataText(*right)
+	}
+
+	mp.textView.SetText(colorDiff(tview.Escape(leftText), tview.Escape(rightText)))
+}
+
+// metadataText lays out all of the metadata of a setting, one field per line
+func metadataText(s azappconfig.Setting) string {
+	lastModified := ""
+	if s.LastModified != nil {
+		lastModified = s.LastModified.Local().Format(time.RFC1123)
+	}
+
+	etag := ""
+	if s.ETag != nil {
+		etag = string(*s.ETag)
+	}
+
+	lines := []string{
+		fmt.Sprintf("Label:         %s", labelText(s.Label)),
+		fmt.Sprintf("Content Type:  %s", derefOr(s.ContentType, "")),
+		fmt.Sprintf("Last Modified: %s", lastModified),
+		fmt.Sprintf("ETag:          %s", etag),
+		fmt.Sprintf("Read Only:     %t", s.IsReadOnly != nil && *s.IsReadOnly),
+		fmt.Sprintf("Tags:          %s", strings.Join(tagLines(s.Tags), ", ")),
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// metadataChanges lists the metadata of a setting that is meaningful to compare between revisions,
+// so excluding the ETag and modification time which differ for every revision
+func metadataChanges(s azappconfig.Setting) string {
+	lines := []string{
+		fmt.Sprintf("label: %s", labelText(s.Label)),
+		fmt.Sprintf("content type: %s", derefOr(s.ContentType, "")),
+		fmt.Sprintf("read only: %t", s.IsReadOnly != nil && *s.IsReadOnly),
+	}
+
+	for _, tag := range tagLines(s.Tags) {
+		lines = append(lines, fmt.Sprintf("tag: %s", tag))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// tagLines formats tags as name=value, sorted by name
+func tagLines(tags map[string]*string) []string {
+	lines := []string{}
+	for name, value := range tags {
+		lines = append(lines, fmt.Sprintf("%s=%s", name, derefOr(value, "")))
+	}
+	slices.Sort(lines)
+
+	return lines
+}
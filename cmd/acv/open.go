@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// fileExtensions are the extensions of temporary files for each render type, so that editors
+// recognise what they are opening
+var fileExtensions = map[RenderType]string{
+	Plain:    ".txt",
+	Json:     ".json",
+	JsonTree: ".json",
+	Yaml:     ".yaml",
+	Xml:      ".xml",
+	Toml:     ".toml",
+	Base64:   ".txt",
+	Jwt:      ".txt",
+}
+
+// openInEditor opens the value as shown in $VISUAL or $EDITOR
+func openInEditor() {
+	openValue([]string{"VISUAL", "EDITOR"}, "vi")
+}
+
+// openInPager opens the value as shown in $PAGER
+func openInPager() {
+	openValue([]string{"PAGER"}, "less")
+}
+
+// openValue writes the value as shown to a read-only temporary file, then suspends the UI while
+// it is opened by the program named by the first of envVars which isn't blank
+func openValue(envVars []string, fallback string) {
+	value := valuesManager.plainValue()
+	if value == "" {
+		statusBar.SetMessage("No value to open")
+		return
+	}
+
+	args := []string{fallback}
+	for _, envVar := range envVars {
+		// Allow for arguments, e.g. EDITOR="code --wait"
+		if fields := strings.Fields(os.Getenv(envVar)); len(fields) > 0 {
+			args = fields
+			break
+		}
+	}
+
+	extension := fileExtensions[valuesManager.renderType]
+	if viewMode == Diff {
+		extension = ".diff"
+	}
+
+	path, err := writeTempFile(value, extension)
+	if err != nil {
+		statusBar.SetError(err)
+		return
+	}
+	defer os.Remove(path)
+
+	var runErr error
+	app.Suspend(func() {
+		cmd := exec.Command(args[0], append(args[1:], path)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		runErr = cmd.Run()
+	})
+
+	if runErr != nil {
+		statusBar.SetError(errors.Wrapf(runErr, "failed to run %s", args[0]))
+	}
+}
+
+func writeTempFile(value string, extension string) (string, error) {
+	file, err := os.CreateTemp("", "acv-*"+extension)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temporary file")
+	}
+
+	_, err = file.WriteString(value)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// Opening is for inspection, not editing
+		err = os.Chmod(file.Name(), 0o400)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", errors.Wrap(err, "failed to write temporary file")
+	}
+
+	return file.Name(), nil
+}
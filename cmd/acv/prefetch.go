@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+)
+
+const (
+	// prefetchRadius is how many keys either side of the cursor have their revisions fetched
+	// before they are selected
+	prefetchRadius = 5
+
+	// prefetchWorkers is how many revisions are fetched at once
+	prefetchWorkers = 4
+)
+
+// Prefetcher fetches the revisions of the settings around the cursor in the background, so
+// that selecting one of them is instant. Revisions are kept in memory by server, key and label,
+// along with the ETag of the setting they were fetched for, which tells when they are out of date.
+type Prefetcher struct {
+	jobs chan prefetchJob
+
+	lock      sync.Mutex
+	revisions map[string]prefetched
+	// cancel stops fetching for wherever the cursor was before
+	cancel context.CancelFunc
+}
+
+type prefetchJob struct {
+	ctx     context.Context
+	client  *azappconfig.Client
+	server  string
+	setting azappconfig.Setting
+}
+
+type prefetched struct {
+	etag      string
+	revisions []azappconfig.Setting
+}
+
+var prefetcher = NewPrefetcher()
+
+// NewPrefetcher starts the pool of workers which fetch revisions
+func NewPrefetcher() *Prefetcher {
+	p := &Prefetcher{
+		jobs:      make(chan prefetchJob),
+		revisions: map[string]prefetched{},
+		cancel:    func() {},
+	}
+
+	for range prefetchWorkers {
+		go p.work()
+	}
+
+	return p
+}
+
+// Prefetch fetches the revisions of settings, in order, which haven't been already. Anything
+// still to be fetched from an earlier call is abandoned.
+func (p *Prefetcher) Prefetch(client *azappconfig.Client, server string, settings []azappconfig.Setting) {
+	p.lock.Lock()
+	p.cancel()
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.lock.Unlock()
+
+	go func() {
+		for _, setting := range settings {
+			if _, ok := p.Revisions(server, setting); ok {
+				continue
+			}
+
+			select {
+			case p.jobs <- prefetchJob{ctx, client, server, setting}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Revisions returns the revisions of a setting, if they have been fetched since it last changed
+func (p *Prefetcher) Revisions(server string, setting azappconfig.Setting) ([]azappconfig.Setting, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	cached, ok := p.revisions[prefetchID(server, setting)]
+	if !ok || cached.etag != string(derefOr(setting.ETag, "")) {
+		return nil, false
+	}
+	return cached.revisions, true
+}
+
+// Store keeps the revisions of a setting, however they were fetched
+func (p *Prefetcher) Store(server string, setting azappconfig.Setting, revisions []azappconfig.Setting) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.revisions[prefetchID(server, setting)] = prefetched{
+		etag:      string(derefOr(setting.ETag, "")),
+		revisions: revisions,
+	}
+}
+
+func (p *Prefetcher) work() {
+	for job := range p.jobs {
+		if job.ctx.Err() != nil {
+			continue
+		}
+
+		revisions, err := listRevisions(job.ctx, job.client, escapeFilter(*job.setting.Key), labelFilter(job.setting.Label), nil)
+		if err != nil {
+			// Left to be fetched, and any error shown, when the setting is selected
+			continue
+		}
+
+		// Saved before anything else can see them
+		if settingsCache != nil {
+			settingsCache.SaveRevisions(job.server, *job.setting.Key, job.setting.Label, revisions)
+		}
+		p.Store(job.server, job.setting, revisions)
+	}
+}
+
+func prefetchID(server string, setting azappconfig.Setting) string {
+	return server + "\x00\x00" + settingID(setting)
+}
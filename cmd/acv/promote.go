@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/pkg/errors"
+)
+
+type PromotionStatus int
+
+const (
+	PromoteNew PromotionStatus = iota
+	PromoteChanged
+	PromoteUnchanged
+	PromoteConflict
+)
+
+var promotionStatusNames = map[PromotionStatus]string{
+	PromoteNew:       "new",
+	PromoteChanged:   "changed",
+	PromoteUnchanged: "unchanged",
+	PromoteConflict:  "CONFLICT",
+}
+
+// Promotion is the plan for promoting a single setting to its target
+type Promotion struct {
+	source azappconfig.Setting
+	// target is the existing setting at the destination, if there is one
+	target   *azappconfig.Setting
+	status   PromotionStatus
+	conflict string
+}
+
+// promotionLedger remembers the ETag of every setting acv has promoted to, by server then setting ID,
+// so that changes made to a target since it was last promoted to can be spotted
+type promotionLedger map[string]map[string]string
+
+// promote copies the bulk targets with one label to another label, in this or another store,
+// after showing a plan of what will change
+func promote() {
+	if err := checkWritable(); err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	candidates := bulkTargets()
+	if len(candidates) == 0 {
+		return
+	}
+
+	initialLabel := ""
+	if selected := keysManager.selectedSetting(); selected != nil {
+		initialLabel = derefOr(selected.Label, "")
+	}
+
+	prompt("Promote from label", "Source label: ", initialLabel, func(text string) {
+		sourceLabel := parseLabel(text)
+		sources := reduce(candidates, func(s azappconfig.Setting) bool {
+			return labelText(s.Label) == labelText(sourceLabel)
+		})
+
+		if len(sources) == 0 {
+			statusBar.SetMessage(fmt.Sprintf("None of the %d settings have label [%s]", len(candidates), labelText(sourceLabel)))
+			return
+		}
+
+		choose(
+			fmt.Sprintf("Promote %d settings to store", len(sources)),
+			arraymap(configServers, func(server string) Choice {
+				name := server
+				if server == currentServer {
+					name = fmt.Sprintf("%s (this store)", server)
+				}
+
+				return Choice{
+					Name: name,
+					Selected: func() {
+						prompt("Promote to label", "Target label: ", "", func(text string) {
+							planPromotion(sources, server, parseLabel(text))
+						})
+					},
+				}
+			}),
+		)
+	})
+}
+
+// planPromotion works out what promoting would do to each target, and asks for confirmation
+func planPromotion(sources []azappconfig.Setting, targetServer string, targetLabel *string) {
+	targetClient := client
+	if targetServer != currentServer {
+		var err error
+		targetClient, err = newClient(targetServer)
+		if err != nil {
+			statusBar.SetError(errors.Wrapf(err, "failed to connect to %s", targetServer))
+			return
+		}
+	}
+
+	ledger, err := loadPromotionLedger()
+	if err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	plan := []Promotion{}
+	for _, source := range sources {
+		promotion, err := planSettingPromotion(targetClient, ledger[targetServer], source, targetLabel)
+		if err != nil {
+			statusBar.SetError(errors.Wrapf(err, "failed to check target of %s", *source.Key))
+			return
+		}
+		plan = append(plan, promotion)
+	}
+
+	counts := map[PromotionStatus]int{}
+	for _, p := range plan {
+		counts[p.status]++
+	}
+
+	items := arraymap(plan, func(p Promotion) string {
+		line := fmt.Sprintf("%-9s %s", promotionStatusNames[p.status], *p.source.Key)
+		if p.conflict != "" {
+			line = fmt.Sprintf("%s (%s, will be skipped)", line, p.conflict)
+		}
+		return line
+	})
+
+	if counts[PromoteNew]+counts[PromoteChanged] == 0 {
+		statusBar.SetMessage(fmt.Sprintf("Nothing to promote: %d unchanged, %d conflicts", counts[PromoteUnchanged], counts[PromoteConflict]))
+		return
+	}
+
+	confirm(
+		fmt.Sprintf(
+			"Promote to %s [%s]: %d new, %d changed, %d unchanged, %d conflicts",
+			targetServer,
+			labelText(targetLabel),
+			counts[PromoteNew],
+			counts[PromoteChanged],
+			counts[PromoteUnchanged],
+			counts[PromoteConflict],
+		),
+		items,
+		func() {
+			applyPromotion(plan, targetClient, targetServer, targetLabel, ledger)
+		},
+	)
+}
+
+func planSettingPromotion(
+	targetClient *azappconfig.Client,
+	promoted map[string]string,
+	source azappconfig.Setting,
+	targetLabel *string,
+) (Promotion, error) {
+	promotion := Promotion{
+		source: source,
+		status: PromoteNew,
+	}
+
+	resp, err := targetClient.GetSetting(
+		eventLoop,
+		*source.Key,
+		&azappconfig.GetSettingOptions{
+			Label: targetLabel,
+		},
+	)
+	if err != nil {
+		var responseError *azcore.ResponseError
+		if errors.As(err, &responseError) && responseError.StatusCode == http.StatusNotFound {
+			return promotion, nil
+		}
+		return promotion, err
+	}
+
+	target := resp.Setting
+	promotion.target = &target
+
+	lastPromotedETag, promotedBefore := promoted[settingID(target)]
+
+	switch {
+	case derefOr(target.IsReadOnly, false):
+		promotion.status = PromoteConflict
+		promotion.conflict = "target is locked"
+	case promotedBefore && target.ETag != nil && string(*target.ETag) != lastPromotedETag:
+		promotion.status = PromoteConflict
+		promotion.conflict = "target modified since last promote"
+	case derefOr(source.Value, "") == derefOr(target.Value, "") &&
+		derefOr(source.ContentType, "") == derefOr(target.ContentType, "") &&
+		maps.EqualFunc(source.Tags, target.Tags, func(a *string, b *string) bool {
+			return derefOr(a, "") == derefOr(b, "")
+		}):
+		promotion.status = PromoteUnchanged
+	default:
+		promotion.status = PromoteChanged
+	}
+
+	return promotion, nil
+}
+
+// applyPromotion writes the new and changed settings of a plan, stopping at the first failure.
+// Changed settings are only overwritten if they are still as they were when planned.
+func applyPromotion(
+	plan []Promotion,
+	targetClient *azappconfig.Client,
+	targetServer string,
+	targetLabel *string,
+	ledger promotionLedger,
+) {
+	toWrite := reduce(plan, func(p Promotion) bool {
+		return p.status == PromoteNew || p.status == PromoteChanged
+	})
+
+	if ledger[targetServer] == nil {
+		ledger[targetServer] = map[string]string{}
+	}
+
+	written := []azappconfig.Setting{}
+	defer func() {
+		if err := savePromotionLedger(ledger); err != nil {
+			statusBar.SetError(err)
+		}
+
+		if targetServer == currentServer {
+			upsertSettings(written)
+		}
+	}()
+
+	for _, p := range toWrite {
+		promoted, err := writePromotion(targetClient, p, targetLabel)
+		if err != nil {
+			statusBar.SetError(errors.Wrapf(err, "failed to promote %s after %d of %d", *p.source.Key, len(written), len(toWrite)))
+			return
+		}
+
+		if promoted.ETag != nil {
+			ledger[targetServer][settingID(promoted)] = string(*promoted.ETag)
+		}
+		written = append(written, promoted)
+	}
+
+	statusBar.SetMessage(fmt.Sprintf("Promoted %d settings to %s [%s]", len(written), targetServer, labelText(targetLabel)))
+}
+
+// writePromotion adds a new target, or overwrites an existing one only if it hasn't changed
+func writePromotion(targetClient *azappconfig.Client, p Promotion, targetLabel *string) (azappconfig.Setting, error) {
+	if p.target == nil {
+		resp, err := targetClient.AddSetting(
+			eventLoop,
+			*p.source.Key,
+			p.source.Value,
+			&azappconfig.AddSettingOptions{
+				Label:       targetLabel,
+				ContentType: p.source.ContentType,
+				Tags:        p.source.Tags,
+			},
+		)
+		return resp.Setting, err
+	}
+
+	resp, err := targetClient.SetSetting(
+		eventLoop,
+		*p.source.Key,
+		p.source.Value,
+		&azappconfig.SetSettingOptions{
+			Label:           targetLabel,
+			ContentType:     p.source.ContentType,
+			Tags:            p.source.Tags,
+			OnlyIfUnchanged: p.target.ETag,
+		},
+	)
+	return resp.Setting, err
+}
+
+func promotionLedgerPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find config directory")
+	}
+
+	return filepath.Join(configDir, "acv", "promotions.json"), nil
+}
+
+func loadPromotionLedger() (promotionLedger, error) {
+	ledger := promotionLedger{}
+
+	path, err := promotionLedgerPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ledger, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read promotions")
+	}
+
+	if err := json.Unmarshal(data, &ledger); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	return ledger, nil
+}
+
+func savePromotionLedger(ledger promotionLedger) error {
+	path, err := promotionLedgerPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.Wrap(err, "failed to save promotions")
+	}
+
+	data, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to save promotions")
+	}
+
+	return errors.Wrap(os.WriteFile(path, data, 0o600), "failed to save promotions")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// PromptDialog asks for a single line of text, e.g. a label or file name
+type PromptDialog struct {
+	// UI Layout
+	layout tview.Primitive
+	form   *tview.Form
+	input  *tview.InputField
+
+	// Events and Callbacks
+	submitFunc func(string)
+	cancelFunc func()
+}
+
+var _ UIComponent = (*PromptDialog)(nil)
+
+func NewPromptDialog() *PromptDialog {
+	dialog := &PromptDialog{}
+
+	dialog.input = tview.NewInputField().
+		SetFieldBackgroundColor(tcell.ColorBlack).
+		SetFieldTextColor(tcell.ColorAntiqueWhite)
+
+	dialog.form = tview.NewForm().
+		AddFormItem(dialog.input).
+		SetButtonsAlign(tview.AlignCenter).
+		SetButtonBackgroundColor(tcell.ColorBlue).
+		AddButton("OK", func() {
+			dialog.submitFunc(dialog.input.GetText())
+		}).
+		AddButton("Cancel", func() {
+			dialog.cancelFunc()
+		})
+
+	dialog.form.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			dialog.cancelFunc()
+			return nil
+		case tcell.KeyEnter:
+			if dialog.input.HasFocus() {
+				dialog.submitFunc(dialog.input.GetText())
+				return nil
+			}
+		}
+		return event
+	})
+
+	dialog.form.
+		SetBorder(true).
+		SetBorderColor(tcell.ColorBlue).
+		SetBackgroundColor(tcell.ColorBlack)
+
+	dialog.layout = centered(dialog.form, 80, 7)
+
+	return dialog
+}
+
+func (pd *PromptDialog) GetPrimitive() tview.Primitive {
+	return pd.layout
+}
+
+// Setup prepares the dialog to ask for some text, starting with an initial value. Exactly one
+// of submitFunc or cancelFunc will be called when the user responds.
+func (pd *PromptDialog) Setup(
+	title string,
+	label string,
+	initial string,
+	submitFunc func(string),
+	cancelFunc func(),
+) {
+	pd.submitFunc = submitFunc
+	pd.cancelFunc = cancelFunc
+
+	pd.form.SetTitle(fmt.Sprintf(" %s ", tview.Escape(title)))
+	pd.input.SetLabel(label).SetText(initial)
+	pd.form.SetFocus(0)
+}
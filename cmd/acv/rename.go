@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/pkg/errors"
+)
+
+// renamePrefix moves every setting under one key prefix to another prefix, across all labels
+func renamePrefix() {
+	if err := checkWritable(); err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	// Suggest the "folder" of the selected key
+	initialPrefix := ""
+	if selected := keysManager.selectedSetting(); selected != nil {
+		if i := strings.LastIndex(*selected.Key, "/"); i >= 0 {
+			initialPrefix = (*selected.Key)[:i+1]
+		}
+	}
+
+	prompt("Rename key prefix", "From prefix: ", initialPrefix, func(oldPrefix string) {
+		if oldPrefix == "" {
+			statusBar.SetMessage("Can't rename an empty prefix")
+			return
+		}
+
+		prompt(fmt.Sprintf("Rename %s to", oldPrefix), "To prefix: ", oldPrefix, func(newPrefix string) {
+			planRename(oldPrefix, newPrefix)
+		})
+	})
+}
+
+// planRename previews the rename of every affected key and label, refusing if any can't be moved
+func planRename(oldPrefix string, newPrefix string) {
+	if newPrefix == oldPrefix {
+		return
+	}
+
+	sources, err := listSettings(client, escapeFilter(oldPrefix)+"*", "*", nil)
+	if err != nil {
+		statusBar.SetError(err)
+		return
+	}
+
+	if len(sources) == 0 {
+		statusBar.SetMessage(fmt.Sprintf("No keys start with %s", oldPrefix))
+		return
+	}
+
+	existing, err := listSettings(client, escapeFilter(newPrefix)+"*", "*", nil)
+	if err != nil {
+		statusBar.SetError(err)
+		return
+	}
+	existingIDs := map[string]bool{}
+	for _, s := range existing {
+		existingIDs[settingID(s)] = true
+	}
+
+	blocked := 0
+	items := arraymap(sources, func(s azappconfig.Setting) string {
+		renamed := renamedSetting(s, oldPrefix, newPrefix)
+		line := fmt.Sprintf("%s -> %s", settingDisplayName(s), *renamed.Key)
+
+		if derefOr(s.IsReadOnly, false) {
+			blocked++
+			return fmt.Sprintf("%s (BLOCKED: locked)", line)
+		}
+		if existingIDs[settingID(renamed)] {
+			blocked++
+			return fmt.Sprintf("%s (BLOCKED: target exists)", line)
+		}
+		return line
+	})
+
+	if blocked > 0 {
+		inform(fmt.Sprintf("Can't rename, %d of %d settings are blocked", blocked, len(sources)), items)
+		return
+	}
+
+	confirm(
+		fmt.Sprintf("Rename %d settings from %s to %s?", len(sources), oldPrefix, newPrefix),
+		items,
+		func() {
+			applyRename(sources, oldPrefix, newPrefix)
+		},
+	)
+}
+
+// applyRename creates every renamed setting, then deletes every original. If anything fails,
+// whatever has been done so far is rolled back.
+func applyRename(sources []azappconfig.Setting, oldPrefix string, newPrefix string) {
+	created := []azappconfig.Setting{}
+	for _, s := range sources {
+		c, err := recreateSetting(renamedSetting(s, oldPrefix, newPrefix))
+		if err != nil {
+			rollbackRename(created, nil, errors.Wrapf(err, "failed to create %s", settingDisplayName(renamedSetting(s, oldPrefix, newPrefix))))
+			return
+		}
+		created = append(created, c)
+	}
+
+	deleted := []azappconfig.Setting{}
+	for _, s := range sources {
+		if _, err := deleteSetting(s); err != nil {
+			rollbackRename(created, deleted, errors.Wrapf(err, "failed to delete %s", settingDisplayName(s)))
+			return
+		}
+		deleted = append(deleted, s)
+	}
+
+	removeFromSettings(deleted)
+	upsertSettings(created)
+	statusBar.SetMessage(fmt.Sprintf("Renamed %d settings from %s to %s", len(sources), oldPrefix, newPrefix))
+}
+
+// rollbackRename restores deleted originals and removes created copies, reporting the failure
+// that caused the rollback along with anything that couldn't be undone
+func rollbackRename(created []azappconfig.Setting, deleted []azappconfig.Setting, cause error) {
+	failures := []string{}
+
+	for _, s := range deleted {
+		if _, err := recreateSetting(s); err != nil {
+			failures = append(failures, fmt.Sprintf("restore %s", settingDisplayName(s)))
+		}
+	}
+
+	for _, s := range created {
+		if _, err := deleteSetting(s); err != nil {
+			failures = append(failures, fmt.Sprintf("remove %s", settingDisplayName(s)))
+		}
+	}
+
+	if len(failures) > 0 {
+		statusBar.SetError(errors.Wrapf(cause, "rename rolled back, but failed to %s", strings.Join(failures, ", ")))
+		return
+	}
+
+	statusBar.SetError(errors.Wrap(cause, "rename rolled back"))
+}
+
+// renamedSetting is a copy of s with oldPrefix of its key replaced by newPrefix
+func renamedSetting(s azappconfig.Setting, oldPrefix string, newPrefix string) azappconfig.Setting {
+	key := newPrefix + strings.TrimPrefix(*s.Key, oldPrefix)
+	s.Key = &key
+	s.ETag = nil
+	return s
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// renderers turn a raw value into how it is displayed for each render type, or fail
+// if the value isn't valid for that type
+var renderers = map[RenderType]func(string) (string, error){
+	Plain:    renderPlain,
+	Json:     renderJson,
+	JsonTree: renderJson,
+	Yaml:     renderYaml,
+	Xml:      renderXml,
+	Toml:     renderToml,
+	Base64:   renderBase64,
+	Jwt:      renderJwt,
+}
+
+// renderValue renders a value with a render type. On failure the value is returned unchanged,
+// along with an error saying why.
+func renderValue(t RenderType, value string) (string, error) {
+	render, ok := renderers[t]
+	if !ok {
+		return value, nil
+	}
+
+	rendered, err := render(value)
+	if err != nil {
+		return value, errors.Wrapf(err, "not valid %s", renderTypeNames[t])
+	}
+
+	return rendered, nil
+}
+
+// renderTypeForContentType picks the best way to render a value from its content type,
+// e.g. application/json or application/vnd.microsoft.appconfig.ff+json
+func renderTypeForContentType(contentType string) RenderType {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Plain
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return Json
+	case mediaType == "application/yaml" || mediaType == "application/x-yaml" ||
+		mediaType == "text/yaml" || mediaType == "text/x-yaml" || strings.HasSuffix(mediaType, "+yaml"):
+		return Yaml
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return Xml
+	case mediaType == "application/toml" || mediaType == "text/toml":
+		return Toml
+	case mediaType == "application/jwt":
+		return Jwt
+	case mediaType == "application/base64":
+		return Base64
+	}
+
+	return Plain
+}
+
+func renderPlain(value string) (string, error) {
+	return value, nil
+}
+
+func renderJson(value string) (string, error) {
+	var prettyJSON bytes.Buffer
+	if err := json.Indent(&prettyJSON, []byte(value), "", "   "); err != nil {
+		return "", err
+	}
+
+	return prettyJSON.String(), nil
+}
+
+// renderYaml re-indents YAML, keeping comments and key order. As JSON is YAML, this
+// also shows JSON values as YAML.
+func renderYaml(value string) (string, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &document); err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// renderXml re-indents XML, one element per line
+func renderXml(value string) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(value))
+	decoder.Strict = true
+
+	var out bytes.Buffer
+	encoder := xml.NewEncoder(&out)
+	encoder.Indent("", "   ")
+
+	elements := 0
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.CharData:
+			// Whitespace between elements is replaced by the encoder's indentation
+			if len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+			token = xml.CharData(bytes.TrimSpace(t))
+		case xml.StartElement:
+			elements++
+		}
+
+		if err := encoder.EncodeToken(xml.CopyToken(token)); err != nil {
+			return "", err
+		}
+	}
+
+	if elements == 0 {
+		return "", errors.New("no elements")
+	}
+
+	if err := encoder.Flush(); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// renderToml checks a value is valid TOML. It is shown as written, as re-encoding would
+// lose comments and the order of keys.
+func renderToml(value string) (string, error) {
+	var document map[string]any
+	if _, err := toml.Decode(value, &document); err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// renderBase64 decodes base64, with or without padding and in either alphabet. Decoded
+// values that aren't text are shown as a hex dump.
+func renderBase64(value string) (string, error) {
+	decoded, err := decodeBase64(strings.TrimSpace(value))
+	if err != nil {
+		return "", err
+	}
+
+	if utf8.Valid(decoded) {
+		return string(decoded), nil
+	}
+
+	return hex.Dump(decoded), nil
+}
+
+func decodeBase64(value string) ([]byte, error) {
+	var err error
+	for _, encoding := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	} {
+		var decoded []byte
+		decoded, err = encoding.DecodeString(value)
+		if err == nil {
+			return decoded, nil
+		}
+	}
+
+	return nil, err
+}
+
+// jwtTimeClaims are the registered claims holding times, shown readably beneath the payload
+var jwtTimeClaims = []string{"iat", "nbf", "exp"}
+
+// renderJwt decodes the header and payload of a JSON Web Token. The signature is not verified.
+func renderJwt(value string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(value), ".")
+	if len(parts) != 3 {
+		return "", errors.Errorf("expected 3 parts separated by dots, found %d", len(parts))
+	}
+
+	header, err := decodeJwtPart(parts[0])
+	if err != nil {
+		return "", errors.Wrap(err, "bad header")
+	}
+
+	payload, err := decodeJwtPart(parts[1])
+	if err != nil {
+		return "", errors.Wrap(err, "bad payload")
+	}
+
+	lines := []string{
+		"Header:",
+		header,
+		"",
+		"Payload:",
+		payload,
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal([]byte(payload), &claims); err == nil {
+		times := []string{}
+		for _, claim := range jwtTimeClaims {
+			if seconds, ok := claims[claim].(float64); ok {
+				times = append(times, fmt.Sprintf("  %s: %s", claim, time.Unix(int64(seconds), 0).UTC().Format(time.RFC1123)))
+			}
+		}
+		if len(times) > 0 {
+			lines = append(lines, "", "Times:")
+			lines = append(lines, times...)
+		}
+	}
+
+	signature := "(none)"
+	if parts[2] != "" {
+		signature = "(present, not verified)"
+	}
+	lines = append(lines, "", fmt.Sprintf("Signature: %s", signature))
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func decodeJwtPart(part string) (string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return "", err
+	}
+
+	return renderJson(string(decoded))
+}
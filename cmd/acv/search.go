@@ -1,30 +1,36 @@
 package main
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
-var searchLabels = map[SearchType]string{
-	NoSearch:     "Search: ",
-	StringSearch: "Search: ",
-}
-
 type SearchManager struct {
 	searchBox         *tview.InputField
+	label             string
 	searchType        SearchType
 	setFocusFunc      func(tview.Primitive)
 	searchChangedFunc func(string)
+
+	// Match options, for searches which allow them
+	matchOptions bool
+	regex        bool
+	ignoreCase   bool
 }
 
 func NewSearchManager(
+	label string,
 	setFocusFunc func(tview.Primitive),
 	searchChangedFunc func(string),
 ) *SearchManager {
 	// Setting search box
 	searchBox := tview.NewInputField().
 		SetFieldBackgroundColor(tcell.ColorBlack).
-		SetLabel(searchLabels[NoSearch])
+		SetLabel(label)
 
 	searchBox.SetBorder(true).
 		SetFocusFunc(func() {
@@ -46,6 +52,7 @@ func NewSearchManager(
 
 	manager := SearchManager{
 		searchBox:         searchBox,
+		label:             label,
 		searchType:        NoSearch,
 		setFocusFunc:      setFocusFunc,
 		searchChangedFunc: searchChangedFunc,
@@ -62,7 +69,50 @@ func (sm *SearchManager) GetPrimitive() tview.Primitive {
 
 func (sm *SearchManager) setSearchType(st SearchType) {
 	sm.searchType = st
-	sm.searchBox.SetLabel(searchLabels[sm.searchType])
+	sm.updateLabel()
+}
+
+// enableMatchOptions lets Ctrl-R toggle regex matching and Ctrl-S toggle case sensitivity
+func (sm *SearchManager) enableMatchOptions() *SearchManager {
+	sm.matchOptions = true
+	return sm
+}
+
+func (sm *SearchManager) updateLabel() {
+	options := []string{}
+	if sm.regex {
+		options = append(options, "regex")
+	}
+	if sm.ignoreCase {
+		options = append(options, "ignore case")
+	}
+
+	label := sm.label
+	if len(options) > 0 {
+		label = fmt.Sprintf("%s (%s): ", strings.TrimSuffix(label, ": "), strings.Join(options, ", "))
+	}
+	sm.searchBox.SetLabel(label)
+}
+
+// pattern compiles search text to a regular expression according to the match options
+func (sm *SearchManager) pattern(text string) (*regexp.Regexp, error) {
+	if !sm.regex {
+		text = regexp.QuoteMeta(text)
+	}
+	if sm.ignoreCase {
+		text = "(?i)" + text
+	}
+
+	return regexp.Compile(text)
+}
+
+// setStatus shows a short note on the search box, e.g. how many matches there are
+func (sm *SearchManager) setStatus(status string) {
+	if status == "" {
+		sm.searchBox.SetTitle("")
+		return
+	}
+	sm.searchBox.SetTitle(fmt.Sprintf(" %s ", tview.Escape(status)))
 }
 
 func (sm *SearchManager) setSearching(st SearchType) {
@@ -89,6 +139,20 @@ func (sm *SearchManager) onInput(event *tcell.EventKey) *tcell.EventKey {
 		sm.exitSearching()
 		sm.searchChangedFunc(sm.searchBox.GetText())
 		return nil
+
+	case tcell.KeyCtrlR:
+		if sm.matchOptions {
+			sm.regex = !sm.regex
+			sm.updateLabel()
+			return nil
+		}
+
+	case tcell.KeyCtrlS:
+		if sm.matchOptions {
+			sm.ignoreCase = !sm.ignoreCase
+			sm.updateLabel()
+			return nil
+		}
 	}
 
 	return event
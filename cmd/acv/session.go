@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+)
+
+// refreshAgeInterval is how often the age of the keys list is updated
+const refreshAgeInterval = 30 * time.Second
+
+// Session is what is remembered about a server while another is being looked at, so that
+// switching back to it is instant
+type Session struct {
+	client   *azappconfig.Client
+	settings []azappconfig.Setting
+	// asOf and labelFilter are what the settings were fetched with, and refreshed is when
+	asOf        *time.Time
+	labelFilter string
+	refreshed   time.Time
+	keysView    KeysView
+}
+
+// sessions are the servers that have been opened in the current tab, by URL
+var sessions = map[string]*Session{}
+
+// refreshed is when the current settings were fetched
+var refreshed time.Time
+
+// switchServer makes a server current. If it has been opened before, it is put back as it was
+// left without fetching anything, otherwise it is connected to and its settings fetched.
+func switchServer(server string) error {
+	if server == currentServer {
+		return nil
+	}
+
+	if currentServer != "" {
+		sessions[currentServer] = &Session{
+			client:      client,
+			settings:    settings,
+			asOf:        asOf,
+			labelFilter: currentLabelFilter,
+			refreshed:   refreshed,
+			keysView:    keysManager.saveView(),
+		}
+	}
+
+	if viewMode == Standard {
+		valuesManager.reset()
+	}
+
+	session, ok := sessions[server]
+	if !ok {
+		if err := connect(server); err != nil {
+			return err
+		}
+		session = &Session{client: client}
+		sessions[server] = session
+	}
+
+	client = session.client
+	currentServer = server
+
+	if ok && sameTime(session.asOf, asOf) && session.labelFilter == currentLabelFilter {
+		settings = session.settings
+		refreshed = session.refreshed
+		updateKeysList()
+		keysManager.restoreView(session.keysView)
+	} else {
+		// New, or last seen at another point in time or with other labels
+		fetchSettings("*")
+		updateKeysList()
+		keysManager.restoreView(KeysView{})
+	}
+
+	updateKeysTitle()
+	return nil
+}
+
+// sameTime is true if both times are now (nil), or are equal
+func sameTime(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// refreshAge describes how long ago the current settings were fetched
+func refreshAge() string {
+	if refreshed.IsZero() {
+		return ""
+	}
+
+	age := time.Since(refreshed)
+	switch {
+	case age < time.Minute:
+		return "Refreshed just now"
+	case age < time.Hour:
+		return fmt.Sprintf("Refreshed %dm ago", int(age.Minutes()))
+	default:
+		return fmt.Sprintf("Refreshed %dh ago", int(age.Hours()))
+	}
+}
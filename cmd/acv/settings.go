@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+)
+
+// filterEscaper escapes the characters that have special meaning in key and label filters
+var filterEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `,`, `\,`)
+
+// settingID is a unique identifier for a setting, which is the combination of its key and label
+func settingID(s azappconfig.Setting) string {
+	if s.Label == nil {
+		return *s.Key
+	}
+	return fmt.Sprintf("%s\x00%s", *s.Key, *s.Label)
+}
+
+// settingDisplayName is how a single setting is referred to in the UI
+func settingDisplayName(s azappconfig.Setting) string {
+	return fmt.Sprintf("%s [%s]", *s.Key, labelText(s.Label))
+}
+
+// labelText is how a setting label is displayed, with the null label shown as "(no label)"
+func labelText(label *string) string {
+	if label == nil {
+		return "(no label)"
+	}
+	return *label
+}
+
+// escapeFilter makes s match exactly when used as a key or label filter
+func escapeFilter(s string) string {
+	return filterEscaper.Replace(s)
+}
+
+// labelFilter is the label filter which matches exactly this label, including the null label
+func labelFilter(label *string) string {
+	if label == nil {
+		return "\x00"
+	}
+	return escapeFilter(*label)
+}
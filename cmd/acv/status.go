@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// StatusBar is a single line at the bottom of the page for short-lived
+// information and error messages
+type StatusBar struct {
+	textView *tview.TextView
+}
+
+var _ UIComponent = (*StatusBar)(nil)
+
+func NewStatusBar() *StatusBar {
+	textView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextColor(tcell.ColorAntiqueWhite)
+	textView.SetBackgroundColor(tcell.ColorBlack)
+
+	return &StatusBar{
+		textView: textView,
+	}
+}
+
+func (sb *StatusBar) GetPrimitive() tview.Primitive {
+	return sb.textView
+}
+
+func (sb *StatusBar) SetMessage(message string) {
+	sb.textView.SetText(tview.Escape(message))
+}
+
+// SetWarning shows a message about something going wrong, which is being dealt with
+func (sb *StatusBar) SetWarning(message string) {
+	sb.textView.SetText(fmt.Sprintf("[yellow]%s[-]", tview.Escape(message)))
+}
+
+func (sb *StatusBar) SetError(err error) {
+	sb.textView.SetText(fmt.Sprintf("[red]%s[-]", tview.Escape(err.Error())))
+}
+
+func (sb *StatusBar) Clear() {
+	sb.textView.SetText("")
+}
@@ -13,6 +13,12 @@ type UIStylesDef struct {
 	// Table cells i.e. for lists
 	TableCellFocus tcell.Style
 	TableCellBlur  tcell.Style
+	// Marked for a bulk action
+	TableCellMarked tcell.Style
+	// Seen to change while watching
+	TableCellAdded   tcell.Style
+	TableCellChanged tcell.Style
+	TableCellRemoved tcell.Style
 
 	// Revision Selectors
 	RevisionSelectorBorderBlur      tcell.Style
@@ -44,6 +50,24 @@ var UIStyles UIStylesDef = UIStylesDef{
 		Bold(false).
 		Background(tcell.ColorBlack),
 
+	TableCellMarked: tcell.Style{}.
+		Foreground(tcell.ColorYellow).
+		Bold(true).
+		Background(tcell.ColorBlack),
+
+	TableCellAdded: tcell.Style{}.
+		Foreground(tcell.ColorGreen).
+		Background(tcell.ColorBlack),
+
+	TableCellChanged: tcell.Style{}.
+		Foreground(tcell.ColorOrange).
+		Background(tcell.ColorBlack),
+
+	TableCellRemoved: tcell.Style{}.
+		Foreground(tcell.ColorRed).
+		StrikeThrough(true).
+		Background(tcell.ColorBlack),
+
 	RevisionSelectorBorderBlur: tcell.Style{}.
 		Foreground(tcell.ColorAntiqueWhite).
 		Background(tcell.ColorBlack),
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+)
+
+// Tab is an independent workspace of keys and values, bound to a server and label filter.
+// The current tab lives in the globals, and is only written back here when switching away.
+type Tab struct {
+	keysManager   *KeysManager
+	valuesManager *ValuesManager
+	client        *azappconfig.Client
+	server        string
+	labelFilter   string
+	settings      []azappconfig.Setting
+	asOf          *time.Time
+	refreshed     time.Time
+	viewMode      ValueDisplayMode
+	// sessions are the servers opened in this tab, so that another tab on the same server
+	// keeps its own view of it
+	sessions map[string]*Session
+}
+
+var (
+	tabs       []*Tab
+	currentTab int
+
+	// currentLabelFilter is the label filter the current tab fetches settings with
+	currentLabelFilter = "*"
+)
+
+// saveTab writes the current workspace back into its tab
+func saveTab() {
+	*tabs[currentTab] = Tab{
+		keysManager:   keysManager,
+		valuesManager: valuesManager,
+		client:        client,
+		server:        currentServer,
+		labelFilter:   currentLabelFilter,
+		settings:      settings,
+		asOf:          asOf,
+		refreshed:     refreshed,
+		viewMode:      viewMode,
+		sessions:      sessions,
+	}
+}
+
+// loadTab makes a tab the current workspace, replacing the one on screen. The workspace
+// being replaced must have been saved, or closed.
+func loadTab(i int) {
+	mainGrid.
+		RemoveItem(keysManager.GetPrimitive()).
+		RemoveItem(valuesManager.GetPrimitive())
+
+	tab := tabs[i]
+	currentTab = i
+	keysManager = tab.keysManager
+	valuesManager = tab.valuesManager
+	client = tab.client
+	currentServer = tab.server
+	currentLabelFilter = tab.labelFilter
+	settings = tab.settings
+	asOf = tab.asOf
+	refreshed = tab.refreshed
+	viewMode = tab.viewMode
+	sessions = tab.sessions
+
+	addWorkspace()
+	header.SetHistorical(asOf)
+	header.SelectServer(currentServer)
+	updateKeysTitle()
+	app.SetFocus(keysManager.keys)
+}
+
+// addWorkspace puts the current keys and values on the main page
+func addWorkspace() {
+	mainGrid.
+		AddItem(keysManager.GetPrimitive(), 1, 0, 3, 1, 0, 0, true).
+		AddItem(valuesManager.GetPrimitive(), 1, 1, 3, 1, 0, 0, false)
+}
+
+// switchTab moves to another tab, by index
+func switchTab(i int) {
+	if i == currentTab || i < 0 || i >= len(tabs) {
+		return
+	}
+
+	saveTab()
+	loadTab(i)
+}
+
+// stepTab moves to the next or previous tab, wrapping around at either end
+func stepTab(step int) {
+	switchTab((currentTab + step + len(tabs)) % len(tabs))
+}
+
+// newTab asks which server and labels to look at, then opens them in a new tab
+func newTab() {
+	openWithLabels := func(server string) {
+		prompt("New tab", "Label filter: ", labelFilterText(currentLabelFilter), func(text string) {
+			openTab(server, parseLabelFilter(text))
+		})
+	}
+
+	if len(configServers) == 1 {
+		openWithLabels(configServers[0])
+		return
+	}
+
+	choices := []Choice{}
+	for i, server := range configServers {
+		shortcut := rune(0)
+		if i < 9 {
+			shortcut = rune('1' + i)
+		}
+		choices = append(choices, Choice{server, shortcut, func() { openWithLabels(server) }})
+	}
+	choose("New tab", choices)
+}
+
+// openTab adds a tab with a fresh workspace, and makes it current
+func openTab(server string, labelFilter string) {
+	saveTab()
+	mainGrid.
+		RemoveItem(keysManager.GetPrimitive()).
+		RemoveItem(valuesManager.GetPrimitive())
+
+	tabs = append(tabs, &Tab{})
+	currentTab = len(tabs) - 1
+	keysManager = newKeysManager()
+	valuesManager = newValuesManager()
+	client = nil
+	currentServer = ""
+	currentLabelFilter = labelFilter
+	settings = nil
+	asOf = nil
+	refreshed = time.Time{}
+	viewMode = Standard
+	sessions = map[string]*Session{}
+
+	addWorkspace()
+	header.SetHistorical(asOf)
+	header.SelectServer(server)
+	updateKeysTitle()
+	app.SetFocus(keysManager.keys)
+}
+
+// closeTab closes the current tab, moving to the one after it, or before it if it was last
+func closeTab() {
+	if len(tabs) == 1 {
+		statusBar.SetMessage("Can't close the only tab")
+		return
+	}
+
+	// The closed tab is still in the globals, so loadTab takes it off the screen
+	tabs = slices.Delete(tabs, currentTab, currentTab+1)
+	loadTab(min(currentTab, len(tabs)-1))
+}
+
+// filterTab asks for the labels the current tab shows, and refetches its settings with them
+func filterTab() {
+	prompt("Filter by label", "Label filter: ", labelFilterText(currentLabelFilter), func(text string) {
+		currentLabelFilter = parseLabelFilter(text)
+
+		if viewMode == Standard {
+			valuesManager.reset()
+		}
+		keysManager.settingSearchManager.Reset()
+		fetchSettings("*")
+		updateKeysList()
+		updateKeysTitle()
+	})
+}
+
+// updateTabBar shows the open tabs in the header
+func updateTabBar() {
+	names := []string{}
+	for i, tab := range tabs {
+		if i == currentTab {
+			names = append(names, tabName(currentServer, currentLabelFilter))
+		} else {
+			names = append(names, tabName(tab.server, tab.labelFilter))
+		}
+	}
+	header.SetTabs(names, currentTab)
+}
+
+// tabName describes a tab by its server's host name, and its labels if they are filtered
+func tabName(server string, labelFilter string) string {
+	name := strings.TrimPrefix(server, "https://")
+	if name == "" {
+		name = "(not connected)"
+	}
+
+	if labelFilter != "*" {
+		name += fmt.Sprintf(" [%s]", labelFilterText(labelFilter))
+	}
+	return name
+}
+
+// parseLabelFilter turns label filter input into a filter for the server. Nothing means all
+// labels, and "(no label)" can be given, alone or in a comma separated list, for the null label.
+func parseLabelFilter(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "*"
+	}
+
+	labels := strings.Split(text, ",")
+	for i, label := range labels {
+		labels[i] = strings.TrimSpace(label)
+		if labels[i] == labelText(nil) {
+			labels[i] = "\x00"
+		}
+	}
+	return strings.Join(labels, ",")
+}
+
+// labelFilterText is the reverse of parseLabelFilter
+func labelFilterText(filter string) string {
+	return strings.ReplaceAll(filter, "\x00", labelText(nil))
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+type ChangeType int
+
+const (
+	Created ChangeType = iota
+	Modified
+	Deleted
+)
+
+var changeTypeNames = map[ChangeType]string{
+	Created:  "created",
+	Modified: "modified",
+	Deleted:  "deleted",
+}
+
+var changeTypeColors = map[ChangeType]tcell.Color{
+	Created:  tcell.ColorGreen,
+	Modified: tcell.ColorYellow,
+	Deleted:  tcell.ColorRed,
+}
+
+// TimelineEntry is a single revision in the store-wide timeline, along with the
+// revision of the same key and label that came before it (if any)
+type TimelineEntry struct {
+	revision   azappconfig.Setting
+	previous   *azappconfig.Setting
+	changeType ChangeType
+}
+
+type TimelineManager struct {
+	// UI Layout
+	grid        *tview.Grid
+	keyFilter   *tview.InputField
+	labelFilter *tview.InputField
+	sinceFilter *tview.InputField
+	untilFilter *tview.InputField
+	entries     *tview.Table
+	diffView    *tview.TextView
+
+	// Events and Callbacks
+	fetchFunc    func(keyFilter string, labelFilter string, until *time.Time) ([]azappconfig.Setting, error)
+	escapeFunc   func()
+	errorFunc    func(error)
+	setFocusFunc func(tview.Primitive)
+
+	// Internal State
+	timeline []TimelineEntry
+}
+
+var _ UIComponent = (*TimelineManager)(nil)
+
+func NewTimelineManager(
+	fetchFunc func(keyFilter string, labelFilter string, until *time.Time) ([]azappconfig.Setting, error),
+	escapeFunc func(),
+	errorFunc func(error),
+	setFocusFunc func(tview.Primitive),
+) *TimelineManager {
+	manager := &TimelineManager{
+		fetchFunc:    fetchFunc,
+		escapeFunc:   escapeFunc,
+		errorFunc:    errorFunc,
+		setFocusFunc: setFocusFunc,
+	}
+
+	manager.keyFilter = manager.newFilterInput("Keys: ", "*")
+	manager.labelFilter = manager.newFilterInput("Label: ", "*")
+	manager.sinceFilter = manager.newFilterInput("Since: ", "24h")
+	manager.untilFilter = manager.newFilterInput("Until: ", "")
+	manager.untilFilter.SetPlaceholder("now")
+
+	manager.entries = tview.NewTable().
+		SetBorders(false).
+		SetSelectable(true, false).
+		SetFixed(1, 0).
+		SetSelectionChangedFunc(func(row int, column int) {
+			manager.showEntry(row)
+		})
+	manager.entries.
+		SetInputCapture(manager.onInput).
+		SetBorder(true).
+		SetTitle("Timeline").
+		SetBorderPadding(0, 0, 1, 1).
+		SetFocusFunc(func() {
+			manager.entries.SetBorderColor(tcell.ColorBlue)
+		}).
+		SetBlurFunc(func() {
+			manager.entries.SetBorderColor(tcell.ColorWhite)
+		})
+
+	manager.diffView = tview.NewTextView().SetDynamicColors(true)
+	manager.diffView.
+		SetBorderPadding(1, 1, 1, 1).
+		SetBorder(true)
+
+	filters := tview.NewGrid().
+		SetColumns(0, -1, -1, -1).
+		AddItem(manager.keyFilter, 0, 0, 1, 1, 0, 0, false).
+		AddItem(manager.labelFilter, 0, 1, 1, 1, 0, 0, false).
+		AddItem(manager.sinceFilter, 0, 2, 1, 1, 0, 0, false).
+		AddItem(manager.untilFilter, 0, 3, 1, 1, 0, 0, false)
+
+	manager.grid = tview.NewGrid().
+		SetRows(3, 0).
+		SetColumns(-3, -4).
+		AddItem(filters, 0, 0, 1, 2, 0, 0, false).
+		AddItem(manager.entries, 1, 0, 1, 1, 0, 0, true).
+		AddItem(manager.diffView, 1, 1, 1, 1, 0, 0, false)
+	manager.grid.SetBackgroundColor(tcell.ColorBlack)
+
+	return manager
+}
+
+func (tm *TimelineManager) GetPrimitive() tview.Primitive {
+	return tm.grid
+}
+
+func (tm *TimelineManager) newFilterInput(label string, text string) *tview.InputField {
+	input := tview.NewInputField().
+		SetLabel(label).
+		SetText(text).
+		SetFieldBackgroundColor(tcell.ColorBlack)
+
+	input.
+		SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			switch event.Key() {
+			case tcell.KeyEscape:
+				tm.setFocusFunc(tm.entries)
+				return nil
+			case tcell.KeyEnter:
+				tm.Refresh()
+				tm.setFocusFunc(tm.entries)
+				return nil
+			case tcell.KeyTab:
+				tm.focusNextFilter(input)
+				return nil
+			}
+			return event
+		}).
+		SetBorder(true).
+		SetFocusFunc(func() {
+			input.SetBorderColor(tcell.ColorBlue)
+		}).
+		SetBlurFunc(func() {
+			input.SetBorderColor(tcell.ColorWhite)
+		})
+
+	return input
+}
+
+func (tm *TimelineManager) focusNextFilter(current *tview.InputField) {
+	filters := []*tview.InputField{tm.keyFilter, tm.labelFilter, tm.sinceFilter, tm.untilFilter}
+	for i, filter := range filters {
+		if filter == current {
+			tm.setFocusFunc(filters[(i+1)%len(filters)])
+			return
+		}
+	}
+}
+
+func (tm *TimelineManager) onInput(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyEscape:
+		tm.escapeFunc()
+		return nil
+	case tcell.KeyTab:
+		tm.setFocusFunc(tm.keyFilter)
+		return nil
+	}
+
+	switch event.Rune() {
+	case 'T':
+		tm.escapeFunc()
+		return nil
+	case 'r':
+		tm.Refresh()
+		return nil
+	}
+
+	return event
+}
+
+// Refresh re-fetches revisions using the current filters and rebuilds the timeline
+func (tm *TimelineManager) Refresh() {
+	since, err := parseTimeTravel(tm.sinceFilter.GetText())
+	if err != nil {
+		tm.errorFunc(err)
+		return
+	}
+
+	until, err := parseTimeTravel(tm.untilFilter.GetText())
+	if err != nil {
+		tm.errorFunc(err)
+		return
+	}
+
+	keyFilter := strings.TrimSpace(tm.keyFilter.GetText())
+	if keyFilter == "" {
+		keyFilter = "*"
+	} else if !strings.HasSuffix(keyFilter, "*") {
+		// Treat the key filter as a prefix
+		keyFilter += "*"
+	}
+
+	labelFilter := strings.TrimSpace(tm.labelFilter.GetText())
+	if labelFilter == "" {
+		labelFilter = "*"
+	}
+
+	revisions, err := tm.fetchFunc(keyFilter, labelFilter, until)
+	if err != nil {
+		tm.errorFunc(err)
+		return
+	}
+
+	tm.timeline = reduce(
+		buildTimeline(revisions),
+		func(e TimelineEntry) bool {
+			return since == nil || !e.revision.LastModified.Before(*since)
+		},
+	)
+
+	tm.updateEntries()
+}
+
+func (tm *TimelineManager) updateEntries() {
+	tm.entries.Clear()
+	tm.diffView.SetText("")
+
+	for col, heading := range []string{"Time", "Change", "Key", "Label"} {
+		tm.entries.SetCell(0, col, tview.NewTableCell(heading).
+			SetTextColor(tcell.ColorBlue).
+			SetSelectable(false))
+	}
+
+	for i, entry := range tm.timeline {
+		row := i + 1
+		tm.entries.SetCell(row, 0, tview.NewTableCell(entry.revision.LastModified.Local().Format(time.DateTime)))
+		tm.entries.SetCell(row, 1, tview.NewTableCell(changeTypeNames[entry.changeType]).
+			SetTextColor(changeTypeColors[entry.changeType]))
+		tm.entries.SetCell(row, 2, tview.NewTableCell(*entry.revision.Key).SetExpansion(1))
+		tm.entries.SetCell(row, 3, tview.NewTableCell(labelText(entry.revision.Label)))
+	}
+
+	tm.entries.SetTitle(fmt.Sprintf("Timeline (%d changes)", len(tm.timeline)))
+	tm.entries.ScrollToBeginning()
+
+	if len(tm.timeline) > 0 {
+		tm.entries.Select(1, 0)
+		tm.showEntry(1)
+	}
+}
+
+// showEntry shows the value diff between the selected revision and the one before it
+func (tm *TimelineManager) showEntry(row int) {
+	index := row - 1
+	if index < 0 || index >= len(tm.timeline) {
+		return
+	}
+
+	entry := tm.timeline[index]
+	previousValue := ""
+	if entry.previous != nil && entry.previous.Value != nil {
+		previousValue = *entry.previous.Value
+	}
+
+	currentValue := ""
+	if entry.revision.Value != nil {
+		currentValue = *entry.revision.Value
+	}
+
+	tm.diffView.SetTitle(tview.Escape(fmt.Sprintf("%s [%s]", *entry.revision.Key, labelText(entry.revision.Label))))
+	tm.diffView.SetText(colorDiff(tview.Escape(previousValue), tview.Escape(currentValue)))
+	tm.diffView.ScrollToBeginning()
+}
+
+// buildTimeline orders revisions across all keys newest first, and infers the kind of change
+// each one represents by comparing it with the previous revision of the same key and label.
+// A revision without a value is taken to be a deletion.
+func buildTimeline(revisions []azappconfig.Setting) []TimelineEntry {
+	byKeyLabel := map[string][]azappconfig.Setting{}
+	for _, r := range revisions {
+		if r.LastModified == nil {
+			continue
+		}
+		id := settingID(r)
+		byKeyLabel[id] = append(byKeyLabel[id], r)
+	}
+
+	timeline := []TimelineEntry{}
+	for _, history := range byKeyLabel {
+		sort.Slice(history, func(i, j int) bool {
+			return history[i].LastModified.Before(*history[j].LastModified)
+		})
+
+		for i, r := range history {
+			entry := TimelineEntry{
+				revision:   r,
+				changeType: Modified,
+			}
+
+			if i > 0 {
+				entry.previous = &history[i-1]
+			}
+
+			if r.Value == nil {
+				entry.changeType = Deleted
+			} else if entry.previous == nil || entry.previous.Value == nil {
+				entry.changeType = Created
+			}
+
+			timeline = append(timeline, entry)
+		}
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].revision.LastModified.After(*timeline[j].revision.LastModified)
+	})
+
+	return timeline
+}
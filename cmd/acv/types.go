@@ -14,6 +14,12 @@ type RenderType int
 const (
 	Plain RenderType = iota
 	Json
+	JsonTree
+	Yaml
+	Xml
+	Toml
+	Base64
+	Jwt
 )
 
 type ValueDisplayMode int
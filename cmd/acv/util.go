@@ -25,3 +25,11 @@ func reduce[T any](t []T, b func(T) bool) []T {
 func mapreduce[T, R any](t []T, b func(T) bool, f func(T) R) []R {
 	return arraymap(reduce(t, b), f)
 }
+
+// derefOr returns the value t points to, or fallback if t is nil
+func derefOr[T any](t *T, fallback T) T {
+	if t == nil {
+		return fallback
+	}
+	return *t
+}
@@ -1,9 +1,9 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 
@@ -13,13 +13,25 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+
+	"urbanwizardry.com/kvv/internal/jsonquery"
+	"urbanwizardry.com/kvv/internal/schema"
 )
 
-var valueTitles = map[RenderType]string{
-	Plain: "Formatting: Plain",
-	Json:  "Formatting: JSON",
+var renderTypeNames = map[RenderType]string{
+	Plain:    "Plain",
+	Json:     "JSON",
+	JsonTree: "JSON Tree",
+	Yaml:     "YAML",
+	Xml:      "XML",
+	Toml:     "TOML",
+	Base64:   "Base64",
+	Jwt:      "JWT",
 }
 
+// renderCycle is the order the render types are stepped through
+var renderCycle = []RenderType{Plain, Json, JsonTree, Yaml, Xml, Toml, Base64, Jwt}
+
 type ValuesManager struct {
 	// Regular display of which setting/versions is selected
 	primaryRevisionSelector *ValuesRevisionSelector
@@ -27,14 +39,29 @@ type ValuesManager struct {
 
 	// Revision value display
 	valueTextView      *tview.TextView
+	jsonTree           *JsonTreeView
 	valueSearchManager *SearchManager
+	valueQueryManager  *SearchManager
+	metadataPanel      *MetadataPanel
+	renderBanner       *tview.TextView
+	validator          *schema.Validator
 
 	// UI Layout
-	grid *tview.Grid
+	grid       *tview.Grid
+	inputsGrid *tview.Grid
 
 	// Internal state
 
-	renderType   RenderType
+	// query is a jq expression applied to values before they are displayed
+	query string
+	// searchPattern matches the search text, while searching the value
+	searchPattern *regexp.Regexp
+	matchCount    int
+	currentMatch  int
+	renderType    RenderType
+	showMetadata  bool
+	// renderError is why the value last shown couldn't be rendered, if it couldn't
+	renderError  error
 	setFocusFunc func(tview.Primitive)
 }
 
@@ -43,6 +70,7 @@ var _ UIComponent = (*ValuesManager)(nil)
 func NewValuesManager(
 	escapeFunc func(),
 	setFocusFunc func(tview.Primitive),
+	copyFunc func(string),
 ) *ValuesManager {
 	manager := &ValuesManager{
 		setFocusFunc: setFocusFunc,
@@ -91,6 +119,15 @@ func NewValuesManager(
 		})
 
 	configValue.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'n':
+			manager.stepMatch(1)
+			return nil
+		case 'N':
+			manager.stepMatch(-1)
+			return nil
+		}
+
 		if event.Key() == tcell.KeyEscape {
 			if manager.primaryRevisionSelector.viewMode == Standard {
 				primaryRevisionSelector.focusRevisionDropdown()
@@ -104,24 +141,53 @@ func NewValuesManager(
 
 	manager.valueTextView = configValue
 
+	// Alternative to the text view for exploring JSON
+	manager.jsonTree = NewJsonTreeView(
+		func() {
+			primaryRevisionSelector.focusRevisionDropdown()
+		},
+		copyFunc,
+	)
+
 	// Value Text Search Bar
 	manager.valueSearchManager = NewSearchManager(
+		"Search: ",
 		func(p tview.Primitive) {
 			setFocusFunc(p)
 		},
 		func(s string) {
-			if s == "" {
-				manager.updateValueBasedOnView()
-			} else {
-				manager.updateValue(strings.Replace(manager.getValueBasedOnView(), s, fmt.Sprintf("[\"search\"]%s[\"\"]", s), -1))
-			}
-
-			configValue.Highlight("search")
-			configValue.ScrollToHighlight()
+			manager.setSearch(s)
 			manager.valueSearchManager.setSearchType(NoSearch)
 		},
+	).enableMatchOptions()
+
+	// Value Query Bar
+	manager.valueQueryManager = NewSearchManager(
+		"Query (jq): ",
+		func(p tview.Primitive) {
+			setFocusFunc(p)
+		},
+		func(s string) {
+			manager.query = strings.TrimSpace(s)
+			if manager.query == "" {
+				manager.valueQueryManager.Reset()
+			}
+			manager.updateValueBasedOnView()
+			manager.valueQueryManager.setSearchType(NoSearch)
+		},
 	)
 
+	manager.inputsGrid = tview.NewGrid().
+		SetColumns(-1, -1).
+		AddItem(manager.valueSearchManager.GetPrimitive(), 0, 0, 1, 1, 0, 0, false).
+		AddItem(manager.valueQueryManager.GetPrimitive(), 0, 1, 1, 1, 0, 0, false)
+
+	manager.metadataPanel = NewMetadataPanel()
+
+	// Shown above the value when it isn't valid for the render type
+	manager.renderBanner = tview.NewTextView().SetTextColor(tcell.ColorWhite)
+	manager.renderBanner.SetBackgroundColor(tcell.ColorDarkRed)
+
 	// Layout Grid
 	grid := tview.NewGrid()
 	manager.grid = grid
@@ -143,8 +209,8 @@ func (vm *ValuesManager) SetDisplayMode(mode ValueDisplayMode) {
 	case Standard:
 		vm.layoutStandard()
 		// Restore a standard view
-		vm.updateValue(vm.formatValue(vm.primaryRevisionSelector.GetCurrentValue()))
 		vm.diffRevisionSelector.Clear()
+		vm.updateValueBasedOnView()
 	case Diff:
 		vm.layoutDiff()
 	}
@@ -153,37 +219,129 @@ func (vm *ValuesManager) SetDisplayMode(mode ValueDisplayMode) {
 func (vm *ValuesManager) layoutStandard() {
 	vm.grid.Clear()
 	vm.grid.
-		SetRows(3, 0, 3).
+		SetRows(3, vm.bannerHeight(), 0, vm.metadataHeight(), 3).
 		AddItem(vm.primaryRevisionSelector.GetPrimitive(), 0, 0, 1, 1, 0, 0, false).
-		AddItem(vm.valueTextView, 1, 0, 1, 1, 0, 0, false).
-		AddItem(vm.valueSearchManager.GetPrimitive(), 2, 0, 1, 1, 0, 0, false)
+		AddItem(vm.valuePrimitive(), 2, 0, 1, 1, 0, 0, false).
+		AddItem(vm.inputsGrid, 4, 0, 1, 1, 0, 0, false)
+
+	if vm.renderError != nil {
+		vm.grid.AddItem(vm.renderBanner, 1, 0, 1, 1, 0, 0, false)
+	}
+	if vm.showMetadata {
+		vm.grid.AddItem(vm.metadataPanel.GetPrimitive(), 3, 0, 1, 1, 0, 0, false)
+	}
 }
 
 func (vm *ValuesManager) layoutDiff() {
 	vm.grid.Clear()
 	vm.grid.
-		SetRows(3, 3, 0, 3).
+		SetRows(3, 3, vm.bannerHeight(), 0, vm.metadataHeight(), 3).
 		AddItem(vm.primaryRevisionSelector.GetPrimitive(), 0, 0, 1, 1, 0, 0, false).
 		AddItem(vm.diffRevisionSelector.GetPrimitive(), 1, 0, 1, 1, 0, 0, false).
-		AddItem(vm.valueTextView, 2, 0, 1, 1, 0, 0, false).
-		AddItem(vm.valueSearchManager.GetPrimitive(), 3, 0, 1, 1, 0, 0, false)
+		AddItem(vm.valueTextView, 3, 0, 1, 1, 0, 0, false).
+		AddItem(vm.inputsGrid, 5, 0, 1, 1, 0, 0, false)
+
+	if vm.renderError != nil {
+		vm.grid.AddItem(vm.renderBanner, 2, 0, 1, 1, 0, 0, false)
+	}
+	if vm.showMetadata {
+		vm.grid.AddItem(vm.metadataPanel.GetPrimitive(), 4, 0, 1, 1, 0, 0, false)
+	}
+}
+
+func (vm *ValuesManager) bannerHeight() int {
+	if vm.renderError != nil {
+		return 1
+	}
+	return 0
+}
+
+func (vm *ValuesManager) metadataHeight() int {
+	if vm.showMetadata {
+		return metadataPanelHeight
+	}
+	return 0
+}
+
+// layout re-lays out the grid for the current display mode
+func (vm *ValuesManager) layout() {
+	if vm.primaryRevisionSelector.viewMode == Standard {
+		vm.layoutStandard()
+	} else {
+		vm.layoutDiff()
+	}
+}
+
+// showingTree is true when the value is being explored as a JSON tree rather than as text.
+// Diffs are always text.
+func (vm *ValuesManager) showingTree() bool {
+	return vm.renderType == JsonTree && vm.primaryRevisionSelector.viewMode == Standard
+}
+
+// valuePrimitive is whichever primitive is displaying the value
+func (vm *ValuesManager) valuePrimitive() tview.Primitive {
+	if vm.showingTree() {
+		return vm.jsonTree.GetPrimitive()
+	}
+	return vm.valueTextView
+}
+
+// toggleMetadata shows or hides the metadata panel beneath the value
+func (vm *ValuesManager) toggleMetadata() {
+	vm.showMetadata = !vm.showMetadata
+	vm.layout()
+	vm.updateMetadata()
+}
+
+func (vm *ValuesManager) updateMetadata() {
+	if vm.primaryRevisionSelector.viewMode == Standard {
+		vm.metadataPanel.showRevision(vm.primaryRevisionSelector.GetCurrentRevision())
+	} else {
+		vm.metadataPanel.showDiff(
+			vm.primaryRevisionSelector.GetCurrentRevision(),
+			vm.diffRevisionSelector.GetCurrentRevision(),
+		)
+	}
 }
 
 func (vm *ValuesManager) setTextViewTitle() {
-	vm.valueTextView.SetTitle(valueTitles[vm.renderType])
+	title := fmt.Sprintf("Formatting: %s", renderTypeNames[vm.renderType])
+	if vm.query != "" {
+		title = fmt.Sprintf("%s | Query: %s", title, vm.query)
+	}
+	vm.valueTextView.SetTitle(tview.Escape(title))
 }
 
 func (vm *ValuesManager) setRenderType(t RenderType) {
 	vm.renderType = t
 	vm.setTextViewTitle()
+	vm.layout()
+}
+
+// cycleRenderType moves on to the next way of rendering values
+func (vm *ValuesManager) cycleRenderType() {
+	next := (slices.Index(renderCycle, vm.renderType) + 1) % len(renderCycle)
+	vm.setRenderType(renderCycle[next])
 }
 
 func (vm *ValuesManager) reset() {
 	vm.primaryRevisionSelector.setRevisions("", []azappconfig.Setting{})
 	vm.valueTextView.SetText("")
+	vm.renderError = nil
+	vm.updateBanner()
+	vm.updateMetadata()
 }
 
+// setPrimaryRevisions shows the revisions of a setting, rendered according to the content type
+// of the latest revision. Without a content type that says how, they're rendered however the
+// last setting was.
 func (vm *ValuesManager) setPrimaryRevisions(settingName string, revisions []azappconfig.Setting) {
+	if len(revisions) > 0 {
+		latest := sortRevisionsNewestFirst(revisions)[0]
+		if renderType := renderTypeForContentType(derefOr(latest.ContentType, "")); renderType != Plain {
+			vm.setRenderType(renderType)
+		}
+	}
 	vm.primaryRevisionSelector.setRevisions(settingName, revisions)
 }
 
@@ -191,40 +349,170 @@ func (vm *ValuesManager) setDiffRightRevisions(settingName string, revisions []a
 	vm.diffRevisionSelector.setRevisions(settingName, revisions)
 }
 
-func (vm *ValuesManager) updateValueToPrimaryRevision() {
-	if len(vm.primaryRevisionSelector.revisions) > 0 {
-		vm.updateValue(vm.formatValue(vm.primaryRevisionSelector.GetCurrentValue()))
+// updateRevisions replaces the revisions of the setting shown by a selector, re-rendering the
+// value if the selected revision changed, without moving focus
+func (vm *ValuesManager) updateRevisions(selector *ValuesRevisionSelector, revisions []azappconfig.Setting) {
+	if selector.replaceRevisions(revisions) {
+		vm.showValue(vm.getValueBasedOnView())
+		vm.showMatch()
 	}
 }
 
 func (vm *ValuesManager) updateValueBasedOnView() {
 	vm.updateValue(vm.getValueBasedOnView())
+	vm.showMatch()
 }
 
 func (vm *ValuesManager) getValueBasedOnView() string {
+	vm.renderError = nil
+	search := vm.newSearchRegions()
+
 	// If Standard mode, format before return the value.
 	// If Diff mode, do the diff thing (does formatting for you)
+	var value string
 	if vm.primaryRevisionSelector.viewMode == Standard {
-		return vm.formatValue(vm.primaryRevisionSelector.GetCurrentValue())
+		// Any validation goes first, so it is seen
+		if result := vm.validate(); result != nil {
+			value = markup(validationLines(result), NoSyntax, search) + "\n\n"
+		}
+		formatted := vm.formatValue(vm.queryValue(vm.primaryRevisionSelector.GetCurrentValue()))
+		value += markup(plainLines(formatted), syntaxFor(vm.renderType, formatted), search)
 	} else {
-		return vm.diffValues()
+		value = vm.diffValues(search)
+	}
+
+	vm.matchCount = 0
+	if search != nil {
+		vm.matchCount = search.count
 	}
+
+	return value
 }
 
-func (vm *ValuesManager) formatValue(value string) string {
-	var printValue string
-	switch vm.renderType {
-	case Plain:
-		printValue = value
-	case Json:
-		var prettyJSON bytes.Buffer
-		error := json.Indent(&prettyJSON, []byte(value), "", "   ")
-		if error != nil {
-			// Can't format as JSON, default to plain
-			printValue = value
-		} else {
-			printValue = prettyJSON.String()
+// queryValue applies the current query, if there is one, to a value
+func (vm *ValuesManager) queryValue(value string) string {
+	if vm.query == "" {
+		return value
+	}
+
+	result, err := jsonquery.Evaluate(value, vm.query)
+	if err != nil {
+		return err.Error()
+	}
+
+	return result
+}
+
+func (vm *ValuesManager) setValidator(validator *schema.Validator) {
+	vm.validator = validator
+}
+
+// plainValue is the value as shown, without any validation results, or the diff when diffing
+func (vm *ValuesManager) plainValue() string {
+	if vm.primaryRevisionSelector.viewMode == Standard {
+		return vm.formatValue(vm.queryValue(vm.primaryRevisionSelector.GetCurrentValue()))
+	}
+	return vm.valueTextView.GetText(true)
+}
+
+// validate validates the current revision against its schema, if it has one
+func (vm *ValuesManager) validate() *schema.Result {
+	revision := vm.primaryRevisionSelector.GetCurrentRevision()
+	if vm.validator == nil || revision == nil || revision.Value == nil {
+		return nil
+	}
+
+	return vm.validator.Validate(*revision.Key, revision.Label, *revision.Value)
+}
+
+// validationLines describe the result of validating a value
+func validationLines(result *schema.Result) []markupLine {
+	if len(result.Violations) == 0 {
+		return []markupLine{{text: fmt.Sprintf("✓ Valid against %s", result.Schema), color: "green"}}
+	}
+
+	lines := []markupLine{{
+		text:  fmt.Sprintf("✗ %d violations of %s", len(result.Violations), result.Schema),
+		color: "red",
+	}}
+	for _, violation := range result.Violations {
+		lines = append(lines, markupLine{text: fmt.Sprintf("  %s", violation), color: "red"})
+	}
+
+	return lines
+}
+
+// setSearch finds the search text in the value, and shows the first match
+func (vm *ValuesManager) setSearch(text string) {
+	vm.searchPattern = nil
+	vm.currentMatch = 0
+
+	if text != "" {
+		pattern, err := vm.valueSearchManager.pattern(text)
+		if err != nil {
+			vm.updateValueBasedOnView()
+			vm.valueSearchManager.setStatus("invalid regex")
+			return
 		}
+		vm.searchPattern = pattern
+	}
+
+	vm.updateValueBasedOnView()
+}
+
+// newSearchRegions finds the matches of the search pattern, if searching
+func (vm *ValuesManager) newSearchRegions() *searchRegions {
+	if vm.searchPattern == nil {
+		return nil
+	}
+
+	return &searchRegions{
+		find: func(line string) [][]int {
+			// Patterns like a* match nothing everywhere, which isn't worth showing
+			return reduce(vm.searchPattern.FindAllStringIndex(line, -1), func(match []int) bool {
+				return match[1] > match[0]
+			})
+		},
+	}
+}
+
+// showMatch highlights and scrolls to the current search match, and shows which match it is
+func (vm *ValuesManager) showMatch() {
+	switch {
+	case vm.searchPattern == nil:
+		vm.valueTextView.Highlight()
+		vm.valueSearchManager.setStatus("")
+	case vm.matchCount == 0:
+		vm.valueTextView.Highlight()
+		vm.valueSearchManager.setStatus("no matches")
+	default:
+		vm.currentMatch = min(vm.currentMatch, vm.matchCount-1)
+		vm.valueTextView.Highlight(matchRegion(vm.currentMatch))
+		vm.valueTextView.ScrollToHighlight()
+		vm.valueSearchManager.setStatus(fmt.Sprintf("%d/%d matches", vm.currentMatch+1, vm.matchCount))
+	}
+}
+
+// stepMatch moves forwards or (with a negative step) backwards through the search matches, wrapping around
+func (vm *ValuesManager) stepMatch(step int) {
+	if vm.matchCount == 0 {
+		return
+	}
+
+	vm.currentMatch = ((vm.currentMatch+step)%vm.matchCount + vm.matchCount) % vm.matchCount
+	vm.showMatch()
+}
+
+// formatValue renders a value with the current render type. If it can't be, the value is
+// returned as is and the failure is kept for the banner.
+func (vm *ValuesManager) formatValue(value string) string {
+	if value == "" {
+		return value
+	}
+
+	printValue, err := renderValue(vm.renderType, value)
+	if err != nil && vm.renderError == nil {
+		vm.renderError = err
 	}
 
 	return printValue
@@ -233,22 +521,86 @@ func (vm *ValuesManager) formatValue(value string) string {
 // updateValue sets the text view to hold this exact string with no more fortmatting,
 // then fiddles with focus and UI aspects
 func (vm *ValuesManager) updateValue(value string) {
+	vm.showValue(value)
+	if vm.showingTree() {
+		vm.setFocusFunc(vm.jsonTree.tree)
+	} else {
+		vm.setFocusFunc(vm.valueTextView)
+	}
+}
+
+// showValue is updateValue without moving focus
+func (vm *ValuesManager) showValue(value string) {
 	vm.setValue(value)
-	vm.setFocusFunc(vm.valueTextView)
+	vm.updateMetadata()
+	vm.updateBanner()
+	if vm.showingTree() {
+		vm.jsonTree.setValue(vm.formatValue(vm.queryValue(vm.primaryRevisionSelector.GetCurrentValue())))
+	}
 	vm.setTextViewTitle()
 }
 
+// updateBanner shows or hides the render error banner
+func (vm *ValuesManager) updateBanner() {
+	text := ""
+	if vm.renderError != nil {
+		text = fmt.Sprintf(" %s (j to change formatting)", vm.renderError)
+	}
+
+	if text != vm.renderBanner.GetText(false) {
+		vm.renderBanner.SetText(text)
+		vm.layout()
+	}
+}
+
 func (vm *ValuesManager) setValue(value string) {
 	vm.valueTextView.SetText(value)
 }
 
-func (vm *ValuesManager) diffValues() string {
-	s := diff.Diff(
-		vm.formatValue(vm.primaryRevisionSelector.GetCurrentValue()),
-		vm.formatValue(vm.diffRevisionSelector.GetCurrentValue()),
+// diffValues diffs the metadata and values of the selected revisions. The metadata is marked
+// up first, as it is shown first, so that search matches are numbered in the order they are seen.
+func (vm *ValuesManager) diffValues(search *searchRegions) string {
+	// Only show metadata when it has changed, and then only the lines that changed
+	metadataDiff := []string{}
+	left := vm.primaryRevisionSelector.GetCurrentRevision()
+	right := vm.diffRevisionSelector.GetCurrentRevision()
+	if left != nil && right != nil {
+		metadataDiff = reduce(
+			strings.Split(diff.Diff(metadataChanges(*left), metadataChanges(*right)), "\n"),
+			func(s string) bool {
+				return strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+")
+			},
+		)
+	}
+
+	metadata := ""
+	if len(metadataDiff) > 0 {
+		metadata = markup(diffLines(metadataDiff), NoSyntax, search)
+	}
+
+	leftValue := vm.formatValue(vm.queryValue(vm.primaryRevisionSelector.GetCurrentValue()))
+	rightValue := vm.formatValue(vm.queryValue(vm.diffRevisionSelector.GetCurrentValue()))
+	valueDiff := markup(
+		diffLines(strings.Split(diff.Diff(leftValue, rightValue), "\n")),
+		syntaxFor(vm.renderType, leftValue),
+		search,
 	)
-	lines := strings.Split(s, "\n")
 
+	if metadata == "" {
+		return valueDiff
+	}
+
+	return fmt.Sprintf("Metadata:\n%s\n\nValue:\n%s", metadata, valueDiff)
+}
+
+// colorDiff produces a line diff of two values, with removed lines in red and added lines in green
+func colorDiff(left string, right string) string {
+	s := diff.Diff(left, right)
+	return colorDiffLines(strings.Split(s, "\n"))
+}
+
+// colorDiffLines colours lines of diff output by whether they were removed or added
+func colorDiffLines(lines []string) string {
 	formatlines := arraymap(lines, func(s string) string {
 		if strings.HasPrefix(s, "-") {
 			return fmt.Sprintf("[red]%s[white]", s)
@@ -264,7 +616,9 @@ func (vm *ValuesManager) diffValues() string {
 
 // UTILITY
 
-func sortRevisionsNewestFirst(versions []azappconfig.Setting) []azappconfig.Setting {
+// sortRevisionsNewestFirst sorts a copy of revisions, as they may be shared with the prefetcher
+func sortRevisionsNewestFirst(revisions []azappconfig.Setting) []azappconfig.Setting {
+	versions := slices.Clone(revisions)
 	sort.Slice(versions, func(i, j int) bool {
 		// We return the inverse of "less", because we want descending order
 		return !versions[i].LastModified.Before(*versions[j].LastModified)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"slices"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
@@ -75,14 +76,8 @@ func (vrs *ValuesRevisionSelector) setRevisions(settingName string, revisions []
 	vrs.revisions = revisions
 	vrs.revisionsDropDown.SetOptions([]string{}, nil)
 	if len(revisions) > 0 {
-		sortedRevisions := sortRevisionsNewestFirst(vrs.revisions)
-		revisionOptions := arraymap(
-			sortedRevisions,
-			func(r azappconfig.Setting) string { return r.LastModified.Format(time.RFC822) },
-		)
-
 		vrs.revisionsDropDown.SetOptions(
-			revisionOptions,
+			revisionOptions(sortRevisionsNewestFirst(vrs.revisions)),
 			vrs.revisionSelected,
 		)
 
@@ -90,8 +85,43 @@ func (vrs *ValuesRevisionSelector) setRevisions(settingName string, revisions []
 	}
 }
 
+// replaceRevisions updates the revisions of the setting being shown, e.g. when it changes in
+// the background. The selected revision is kept, unless it was the latest, when the new latest
+// is selected instead. Returns whether the selected revision changed, which isn't passed on to
+// revisionChangedFunc so that the caller can decide what to update.
+func (vrs *ValuesRevisionSelector) replaceRevisions(revisions []azappconfig.Setting) bool {
+	index, _ := vrs.revisionsDropDown.GetCurrentOption()
+	selected := ""
+	if current := vrs.GetCurrentRevision(); current != nil {
+		selected = string(derefOr(current.ETag, ""))
+	}
+
+	vrs.revisions = sortRevisionsNewestFirst(revisions)
+	vrs.revisionsDropDown.SetOptions(revisionOptions(vrs.revisions), nil)
+
+	newIndex := 0
+	if index > 0 {
+		newIndex = max(slices.IndexFunc(vrs.revisions, func(r azappconfig.Setting) bool {
+			return string(derefOr(r.ETag, "")) == selected
+		}), 0)
+	}
+	vrs.revisionsDropDown.SetCurrentOption(newIndex)
+	vrs.revisionsDropDown.SetSelectedFunc(vrs.revisionSelected)
+
+	current := vrs.GetCurrentRevision()
+	return current == nil || string(derefOr(current.ETag, "")) != selected
+}
+
+// revisionOptions are the revisions as shown in the dropdown
+func revisionOptions(revisions []azappconfig.Setting) []string {
+	return arraymap(
+		revisions,
+		func(r azappconfig.Setting) string { return r.LastModified.Format(time.RFC822) },
+	)
+}
+
 func (vrs *ValuesRevisionSelector) revisionSelected(text string, index int) {
-	vrs.revisionChangedFunc(*vrs.revisions[index].Value)
+	vrs.revisionChangedFunc(derefOr(vrs.revisions[index].Value, ""))
 }
 
 func (vrs *ValuesRevisionSelector) setDisplayMode(mode ValueDisplayMode) {
@@ -135,10 +165,23 @@ func (vrs *ValuesRevisionSelector) setBlurStyle() {
 
 func (vrs *ValuesRevisionSelector) GetCurrentValue() string {
 	index, _ := vrs.revisionsDropDown.GetCurrentOption()
+	if index < 0 || index >= len(vrs.revisions) || vrs.revisions[index].Value == nil {
+		return ""
+	}
 	return *vrs.revisions[index].Value
 }
 
+// GetCurrentRevision returns the selected revision, or nil if there isn't one
+func (vrs *ValuesRevisionSelector) GetCurrentRevision() *azappconfig.Setting {
+	index, _ := vrs.revisionsDropDown.GetCurrentOption()
+	if index < 0 || index >= len(vrs.revisions) {
+		return nil
+	}
+	return &vrs.revisions[index]
+}
+
 func (vrs *ValuesRevisionSelector) Clear() {
 	vrs.revisionsSettingLabel.SetText("")
+	vrs.revisions = []azappconfig.Setting{}
 	vrs.revisionsDropDown.SetOptions([]string{}, nil)
 }
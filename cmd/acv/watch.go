@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/pkg/errors"
+
+	"urbanwizardry.com/kvv/internal/cache"
+)
+
+// watchInterval is how long to wait between checking for changes while watching
+const watchInterval = 10 * time.Second
+
+var (
+	// watching is true while the current tab's settings are being polled for changes.
+	// watchGeneration counts the times watching has started, so that polls from
+	// before it was stopped can tell they are no longer wanted.
+	watching        bool
+	watchGeneration int
+
+	// watched is the last listing of settings seen while watching, and what it lists
+	watched            *cache.Listing
+	watchedServer      string
+	watchedLabelFilter string
+)
+
+// toggleWatch starts or stops watching for changes to the current tab's settings
+func toggleWatch() {
+	if watching {
+		watching = false
+		watchGeneration++
+
+		// Drop the removed settings left in the list
+		if watched != nil && watchedServer == currentServer && watchedLabelFilter == currentLabelFilter && asOf == nil {
+			replaceSettings(watched.All())
+		}
+		watched = nil
+		keysManager.setChanges(nil)
+		updateKeysTitle()
+		statusBar.SetMessage("Stopped watching for changes")
+		return
+	}
+
+	if offline {
+		statusBar.SetError(errOffline)
+		return
+	}
+	if asOf != nil {
+		statusBar.SetError(errors.New("can't watch for changes while time travelling, clear the \"As of\" time first"))
+		return
+	}
+
+	watching = true
+	watchGeneration++
+	pollWatch(watchGeneration)
+	updateKeysTitle()
+	statusBar.SetMessage(fmt.Sprintf("Watching for changes every %s, press w to stop", watchInterval))
+}
+
+// pollWatch checks the current tab's settings for changes in the background, and then waits
+// to do it again. The first check of a tab's settings is what later ones are compared with.
+func pollWatch(generation int) {
+	if generation != watchGeneration {
+		return
+	}
+
+	if client == nil || asOf != nil {
+		// Nothing to watch right now, try again later
+		scheduleWatch(generation)
+		return
+	}
+
+	serverClient, server, labelFilter := client, currentServer, currentLabelFilter
+	earlier := watched
+	if server != watchedServer || labelFilter != watchedLabelFilter {
+		earlier = nil
+	}
+
+	go func() {
+		listing, changed, err := cache.Fetch(context.Background(), serverClient, "*", labelFilter, earlier)
+
+		app.QueueUpdateDraw(func() {
+			if generation != watchGeneration {
+				return
+			}
+			defer scheduleWatch(generation)
+
+			if err != nil {
+				statusBar.SetError(errors.Wrap(err, "failed to check for changes"))
+				return
+			}
+
+			watched, watchedServer, watchedLabelFilter = listing, server, labelFilter
+			if settingsCache != nil && changed {
+				go saveCachedSettings(server, labelFilter, listing)
+			}
+
+			if server != currentServer || labelFilter != currentLabelFilter || asOf != nil {
+				return
+			}
+
+			refreshed = listing.Fetched
+			if earlier != nil && changed {
+				showChanges(earlier.All(), listing.All())
+			}
+			updateKeysTitle()
+		})
+	}()
+}
+
+// scheduleWatch waits, and then checks for changes again if still watching
+func scheduleWatch(generation int) {
+	time.AfterFunc(watchInterval, func() {
+		app.QueueUpdate(func() {
+			pollWatch(generation)
+		})
+	})
+}
+
+// showChanges replaces the listed settings with the latest ones, highlighting any that were
+// added, changed or removed. Removed settings stay in the list until the next change is seen.
+func showChanges(before []azappconfig.Setting, after []azappconfig.Setting) {
+	changes := map[string]Change{}
+
+	previous := map[string]azappconfig.Setting{}
+	for _, s := range before {
+		previous[settingID(s)] = s
+	}
+
+	latest := slices.Clone(after)
+	for _, s := range after {
+		id := settingID(s)
+		if p, ok := previous[id]; !ok {
+			changes[id] = Added
+		} else if derefOr(p.ETag, "") != derefOr(s.ETag, "") {
+			changes[id] = Changed
+		}
+		delete(previous, id)
+	}
+
+	for id, s := range previous {
+		changes[id] = Removed
+		latest = append(latest, s)
+	}
+
+	if len(changes) == 0 {
+		return
+	}
+
+	// Settings come sorted by key, so put the removed ones back where they were
+	slices.SortStableFunc(latest, func(a azappconfig.Setting, b azappconfig.Setting) int {
+		return strings.Compare(*a.Key, *b.Key)
+	})
+
+	replaceSettings(latest)
+	keysManager.setChanges(changes)
+	updateChangedValue(changes)
+
+	counts := map[Change]int{}
+	for _, change := range changes {
+		counts[change]++
+	}
+	statusBar.SetMessage(fmt.Sprintf(
+		"%s: %d added, %d changed, %d removed",
+		time.Now().Format(time.TimeOnly), counts[Added], counts[Changed], counts[Removed],
+	))
+}
+
+// updateChangedValue fetches the revisions of the shown setting again in the background, if it
+// has changed, and shows them without taking focus from wherever the user is
+func updateChangedValue(changes map[string]Change) {
+	manager := valuesManager
+	selector := manager.primaryRevisionSelector
+	if viewMode == Diff {
+		selector = manager.diffRevisionSelector
+	}
+
+	shown := selector.GetCurrentRevision()
+	if shown == nil || changes[settingID(*shown)] != Changed {
+		return
+	}
+
+	// Fetched for the changed setting, rather than what was shown, so as not to get the
+	// revisions from before it changed
+	i := slices.IndexFunc(settings, func(s azappconfig.Setting) bool {
+		return settingID(s) == settingID(*shown)
+	})
+	if i < 0 {
+		return
+	}
+
+	setting, serverClient, server, id := settings[i], client, currentServer, settingID(*shown)
+
+	go func() {
+		revisions, err := listRevisions(context.Background(), serverClient, escapeFilter(*setting.Key), labelFilter(setting.Label), nil)
+		if err == nil {
+			if settingsCache != nil {
+				settingsCache.SaveRevisions(server, *setting.Key, setting.Label, revisions)
+			}
+			prefetcher.Store(server, setting, revisions)
+		}
+
+		app.QueueUpdateDraw(func() {
+			if err != nil {
+				statusBar.SetError(errors.Wrap(err, "failed to update the changed value"))
+				return
+			}
+
+			// Unless the user has moved on to something else
+			current := selector.GetCurrentRevision()
+			if manager != valuesManager || server != currentServer || current == nil || settingID(*current) != id {
+				return
+			}
+			manager.updateRevisions(selector, revisions)
+		})
+	}()
+}
@@ -0,0 +1,152 @@
+// Package auth creates clients for App Config servers, authenticating as each server is configured
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/pkg/errors"
+
+	"urbanwizardry.com/kvv/internal/config"
+)
+
+// Credentials creates Entra ID credentials as servers need them, and shares them between
+// servers configured the same way, so that e.g. signing in to a tenant only happens once
+type Credentials struct {
+	// deviceCodePrompt tells the user how to sign in with a device code
+	deviceCodePrompt func(message string)
+	// clientOptions are given to every client, for how they retry
+	clientOptions *azappconfig.ClientOptions
+	// replaying is true when requests are answered from a recording, so aren't authenticated
+	replaying bool
+
+	lock  sync.Mutex
+	cache map[credentialKey]azcore.TokenCredential
+}
+
+type credentialKey struct {
+	credential string
+	tenantID   string
+	clientID   string
+}
+
+// NewCredentials creates clients which retry as configured, calling throttled (if it isn't
+// nil) whenever a server throttles a request, with the request's context and how long until it
+// is retried if known
+func NewCredentials(
+	deviceCodePrompt func(message string),
+	retry config.Retry,
+	throttled func(ctx context.Context, host string, delay time.Duration),
+) *Credentials {
+	return &Credentials{
+		deviceCodePrompt: deviceCodePrompt,
+		clientOptions:    clientOptions(retry, throttled),
+		cache:            map[credentialKey]azcore.TokenCredential{},
+	}
+}
+
+// NewClient creates a client for a server, with its connection string if it has one, or
+// otherwise its Entra ID credential
+func (c *Credentials) NewClient(server config.Server) (*azappconfig.Client, error) {
+	if server.ConnectionString != "" {
+		return azappconfig.NewClientFromConnectionString(server.ConnectionString, c.clientOptions)
+	}
+
+	if c.replaying {
+		return azappconfig.NewClient(server.URL, replayCredential{}, c.clientOptions)
+	}
+
+	cred, err := c.credential(server)
+	if err != nil {
+		return nil, err
+	}
+
+	return azappconfig.NewClient(server.URL, cred, c.clientOptions)
+}
+
+// UseTransport sends the requests of every client created after it through a transport,
+// e.g. a recording.Recorder
+func (c *Credentials) UseTransport(transport policy.Transporter) {
+	c.clientOptions.Transport = transport
+}
+
+// Replay answers the requests of every client created after it from a recording.Player, and
+// doesn't sign in to Entra ID for them
+func (c *Credentials) Replay(player policy.Transporter) {
+	c.UseTransport(player)
+	c.replaying = true
+}
+
+// replayCredential is a placeholder for the Entra ID credentials used in a recording
+type replayCredential struct{}
+
+func (replayCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "replay", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func (c *Credentials) credential(server config.Server) (azcore.TokenCredential, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := credentialKey{
+		credential: server.Credential,
+		tenantID:   server.TenantID,
+		clientID:   server.ClientID,
+	}
+	if cred, ok := c.cache[key]; ok {
+		return cred, nil
+	}
+
+	cred, err := c.newCredential(server)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to obtain a %s credential for %s", server.AuthMode(), server.URL)
+	}
+
+	c.cache[key] = cred
+	return cred, nil
+}
+
+func (c *Credentials) newCredential(server config.Server) (azcore.TokenCredential, error) {
+	switch server.Credential {
+	case config.AzureCLICredential:
+		return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{
+			TenantID: server.TenantID,
+		})
+	case config.EnvironmentCredential:
+		return azidentity.NewEnvironmentCredential(nil)
+	case config.ManagedIdentityCredential:
+		options := &azidentity.ManagedIdentityCredentialOptions{}
+		if server.ClientID != "" {
+			options.ID = azidentity.ClientID(server.ClientID)
+		}
+		return azidentity.NewManagedIdentityCredential(options)
+	case config.WorkloadIdentityCredential:
+		return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			TenantID: server.TenantID,
+			ClientID: server.ClientID,
+		})
+	case config.DeviceCodeCredential:
+		return azidentity.NewDeviceCodeCredential(&azidentity.DeviceCodeCredentialOptions{
+			TenantID: server.TenantID,
+			ClientID: server.ClientID,
+			UserPrompt: func(ctx context.Context, message azidentity.DeviceCodeMessage) error {
+				c.deviceCodePrompt(message.Message)
+				return nil
+			},
+		})
+	case config.BrowserCredential:
+		return azidentity.NewInteractiveBrowserCredential(&azidentity.InteractiveBrowserCredentialOptions{
+			TenantID: server.TenantID,
+			ClientID: server.ClientID,
+		})
+	}
+
+	return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+		TenantID: server.TenantID,
+	})
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+
+	"urbanwizardry.com/kvv/internal/config"
+)
+
+// App Config throttles heavy listing for seconds at a time, so requests are retried more, and
+// for longer, than the SDK would by default
+const (
+	defaultMaxRetries    = 6
+	defaultRetryDelay    = time.Second
+	defaultMaxRetryDelay = 30 * time.Second
+)
+
+// clientOptions retries requests as configured, telling throttled about any that the server
+// throttles
+func clientOptions(retry config.Retry, throttled func(ctx context.Context, host string, delay time.Duration)) *azappconfig.ClientOptions {
+	options := policy.RetryOptions{
+		MaxRetries:    defaultMaxRetries,
+		RetryDelay:    defaultRetryDelay,
+		MaxRetryDelay: defaultMaxRetryDelay,
+		TryTimeout:    retry.TryTimeout,
+	}
+
+	if retry.MaxRetries != nil {
+		options.MaxRetries = int32(*retry.MaxRetries)
+		if options.MaxRetries == 0 {
+			// Zero is the SDK's default, and negative is none
+			options.MaxRetries = -1
+		}
+	}
+	if retry.RetryDelay != 0 {
+		options.RetryDelay = retry.RetryDelay
+	}
+	if retry.MaxRetryDelay != 0 {
+		options.MaxRetryDelay = retry.MaxRetryDelay
+	}
+
+	clientOptions := &azappconfig.ClientOptions{
+		ClientOptions: azcore.ClientOptions{Retry: options},
+	}
+	if throttled != nil {
+		clientOptions.PerCallPolicies = []policy.Policy{tryCounter{}}
+		clientOptions.PerRetryPolicies = []policy.Policy{throttlePolicy{options, throttled}}
+	}
+
+	return clientOptions
+}
+
+// tryCounter counts the tries of each request, so throttlePolicy knows when they run out
+type tryCounter struct{}
+
+type triesKey struct{}
+
+func (tryCounter) Do(req *policy.Request) (*http.Response, error) {
+	tries := 0
+	return req.WithContext(context.WithValue(req.Raw().Context(), triesKey{}, &tries)).Next()
+}
+
+// throttlePolicy sees each try of a request, and reports those the server throttles which the
+// retry policy will wait to try again. Those it won't retry fail with the throttled response.
+type throttlePolicy struct {
+	options   policy.RetryOptions
+	throttled func(ctx context.Context, host string, delay time.Duration)
+}
+
+func (p throttlePolicy) Do(req *policy.Request) (*http.Response, error) {
+	ctx := req.Raw().Context()
+	try := 1
+	if tries, ok := ctx.Value(triesKey{}).(*int); ok {
+		*tries++
+		try = *tries
+	}
+
+	resp, err := req.Next()
+	if err != nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return resp, err
+	}
+
+	// The retry policy gives up once it has retried enough, or if it would have to wait longer
+	// than it's allowed to, or if the request has been cancelled
+	delay := retryAfter(resp)
+	if try > max(int(p.options.MaxRetries), 0) || delay > p.options.MaxRetryDelay || ctx.Err() != nil {
+		return resp, err
+	}
+
+	p.throttled(ctx, req.Raw().URL.Host, delay)
+	return resp, err
+}
+
+// retryAfter is how long the server asked to wait before retrying, or 0 if it didn't say
+func retryAfter(resp *http.Response) time.Duration {
+	for _, header := range []string{"retry-after-ms", "x-ms-retry-after-ms"} {
+		if ms, err := strconv.Atoi(resp.Header.Get(header)); err == nil {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+
+	return 0
+}
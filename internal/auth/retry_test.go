@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+
+	"urbanwizardry.com/kvv/internal/config"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"not given", http.Header{}, 0},
+		{"seconds", http.Header{"Retry-After": {"3"}}, 3 * time.Second},
+		{"milliseconds", http.Header{"Retry-After-Ms": {"1500"}}, 1500 * time.Millisecond},
+		{"x-ms milliseconds", http.Header{"X-Ms-Retry-After-Ms": {"250"}}, 250 * time.Millisecond},
+		{"milliseconds before seconds", http.Header{"Retry-After-Ms": {"1500"}, "Retry-After": {"3"}}, 1500 * time.Millisecond},
+		{"date in the past", http.Header{"Retry-After": {"Mon, 01 Jan 2024 00:00:00 GMT"}}, 0},
+		{"nonsense", http.Header{"Retry-After": {"soon"}, "Retry-After-Ms": {"later"}}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := retryAfter(&http.Response{Header: test.header}); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	// A date is how long until then
+	at := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	got := retryAfter(&http.Response{Header: http.Header{"Retry-After": {at}}})
+	if got <= 8*time.Second || got > 10*time.Second {
+		t.Errorf("got %s until %s", got, at)
+	}
+}
+
+// throttlingTransport answers each try of a request with the next of its responses, and then
+// with the last of them again
+type throttlingTransport struct {
+	responses []*http.Response
+	tries     int
+}
+
+func (t *throttlingTransport) Do(req *http.Request) (*http.Response, error) {
+	resp := t.responses[min(t.tries, len(t.responses)-1)]
+	t.tries++
+	return &http.Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       io.NopCloser(strings.NewReader(`{"key": "key"}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestThrottlePolicy(t *testing.T) {
+	throttled := func(header ...string) *http.Response {
+		resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+		for i := 0; i < len(header); i += 2 {
+			resp.Header.Set(header[i], header[i+1])
+		}
+		return resp
+	}
+	ok := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Sync-Token": {"token=1;sn=1"}}}
+	retries := func(n int) *int {
+		return &n
+	}
+
+	tests := []struct {
+		name       string
+		retry      config.Retry
+		responses  []*http.Response
+		wantTries  int
+		wantDelays []time.Duration
+	}{
+		{
+			name:       "retried until it succeeds",
+			responses:  []*http.Response{throttled("retry-after-ms", "1"), throttled("retry-after-ms", "2"), ok},
+			wantTries:  3,
+			wantDelays: []time.Duration{time.Millisecond, 2 * time.Millisecond},
+		},
+		{
+			name:       "without saying how long to wait",
+			retry:      config.Retry{RetryDelay: time.Millisecond},
+			responses:  []*http.Response{{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}, ok},
+			wantTries:  2,
+			wantDelays: []time.Duration{0},
+		},
+		{
+			name:       "retries run out",
+			retry:      config.Retry{MaxRetries: retries(2)},
+			responses:  []*http.Response{throttled("retry-after-ms", "1")},
+			wantTries:  3,
+			wantDelays: []time.Duration{time.Millisecond, time.Millisecond},
+		},
+		{
+			name:       "no retries",
+			retry:      config.Retry{MaxRetries: retries(0)},
+			responses:  []*http.Response{throttled("retry-after-ms", "1")},
+			wantTries:  1,
+			wantDelays: nil,
+		},
+		{
+			name:       "longer than MaxRetryDelay",
+			responses:  []*http.Response{throttled("Retry-After", "45")},
+			wantTries:  1,
+			wantDelays: nil,
+		},
+		{
+			name:       "then longer than MaxRetryDelay",
+			retry:      config.Retry{MaxRetryDelay: time.Second},
+			responses:  []*http.Response{throttled("retry-after-ms", "1"), throttled("retry-after-ms", "1500")},
+			wantTries:  2,
+			wantDelays: []time.Duration{time.Millisecond},
+		},
+		{
+			name:       "not throttled",
+			responses:  []*http.Response{ok},
+			wantTries:  1,
+			wantDelays: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var delays []time.Duration
+			options := clientOptions(test.retry, func(ctx context.Context, host string, delay time.Duration) {
+				if host != "example.azconfig.io" {
+					t.Errorf("throttled by %s", host)
+				}
+				delays = append(delays, delay)
+			})
+			transport := &throttlingTransport{responses: test.responses}
+			options.Transport = transport
+
+			client, err := azappconfig.NewClientFromConnectionString("Endpoint=https://example.azconfig.io;Id=test;Secret=c2VjcmV0", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.GetSetting(context.Background(), "key", nil)
+
+			if transport.tries != test.wantTries {
+				t.Errorf("tried %d times, want %d", transport.tries, test.wantTries)
+			}
+			if !slices.Equal(delays, test.wantDelays) {
+				t.Errorf("told of retries after %v, want %v", delays, test.wantDelays)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Package cache keeps fetched settings and revisions on disk, by server, so that they can be
+// shown before fetching them again, or without being able to fetch them at all
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/pkg/errors"
+)
+
+// Cache is a directory of cached settings, with one directory inside it per server
+type Cache struct {
+	dir string
+}
+
+// Listing is the settings matching a filter, as the pages they were fetched in. The ETag of
+// each page lets the server say whether it has changed since.
+type Listing struct {
+	Fetched time.Time
+	Pages   []Page
+}
+
+// Page is one page of a Listing
+type Page struct {
+	ETag     *azcore.ETag
+	Settings []azappconfig.Setting
+}
+
+// Revisions is the revision history of a setting
+type Revisions struct {
+	Fetched   time.Time
+	Revisions []azappconfig.Setting
+}
+
+// New opens the cache in acv under the user cache directory, e.g. ~/.cache/acv
+func New() (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, errors.Wrap(err, "can't find the user cache directory")
+	}
+
+	return &Cache{dir: filepath.Join(dir, "acv")}, nil
+}
+
+// Settings returns the cached listing of settings matching a label filter, or nil if there isn't one
+func (c *Cache) Settings(server string, labelFilter string) (*Listing, error) {
+	var listing Listing
+	ok, err := c.read(c.settingsPath(server, labelFilter), &listing)
+	if !ok {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+// SaveSettings caches a listing of settings matching a label filter
+func (c *Cache) SaveSettings(server string, labelFilter string, listing *Listing) error {
+	return c.write(c.settingsPath(server, labelFilter), listing)
+}
+
+// Revisions returns the cached revisions of a setting, or nil if there aren't any
+func (c *Cache) Revisions(server string, key string, label *string) (*Revisions, error) {
+	var revisions Revisions
+	ok, err := c.read(c.revisionsPath(server, key, label), &revisions)
+	if !ok {
+		return nil, err
+	}
+	return &revisions, nil
+}
+
+// SaveRevisions caches the revisions of a setting
+func (c *Cache) SaveRevisions(server string, key string, label *string, revisions []azappconfig.Setting) error {
+	return c.write(c.revisionsPath(server, key, label), &Revisions{
+		Fetched:   time.Now(),
+		Revisions: revisions,
+	})
+}
+
+func (c *Cache) serverDir(server string) string {
+	return filepath.Join(c.dir, url.PathEscape(strings.TrimPrefix(server, "https://")))
+}
+
+func (c *Cache) settingsPath(server string, labelFilter string) string {
+	return filepath.Join(c.serverDir(server), "settings", hash(labelFilter)+".json")
+}
+
+func (c *Cache) revisionsPath(server string, key string, label *string) string {
+	// The null label is kept apart from the empty one
+	id := key + "\x00"
+	if label != nil {
+		id += "=" + *label
+	}
+	return filepath.Join(c.serverDir(server), "revisions", hash(id)+".json")
+}
+
+// hash names a file for something which might not be allowed in a file name
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
+
+// read decodes a cache file, returning false if it doesn't exist or can't be read
+func (c *Cache) read(path string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "failed to read cache")
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, errors.Wrapf(err, "cache file %s is corrupt", path)
+	}
+	return true, nil
+}
+
+// write replaces a cache file, so that it is never seen half written
+func (c *Cache) write(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode cache")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.Wrap(err, "failed to create cache directory")
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to write cache")
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return errors.Wrap(err, "failed to write cache")
+	}
+	if err := temp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write cache")
+	}
+
+	return errors.Wrap(os.Rename(temp.Name(), path), "failed to write cache")
+}
+
+// All is every setting in the listing, in order
+func (l *Listing) All() []azappconfig.Setting {
+	settings := []azappconfig.Setting{}
+	for _, page := range l.Pages {
+		settings = append(settings, page.Settings...)
+	}
+	return settings
+}
+
+// Fetch lists the live settings matching the filters. Given an earlier listing, each page is
+// only sent again if its ETag has changed, and changed is false if none of them have.
+func Fetch(ctx context.Context, client *azappconfig.Client, keyFilter string, labelFilter string, earlier *Listing) (*Listing, bool, error) {
+	conditions := []azcore.MatchConditions{}
+	if earlier != nil {
+		for _, page := range earlier.Pages {
+			conditions = append(conditions, azcore.MatchConditions{IfNoneMatch: page.ETag})
+		}
+	}
+
+	pager := client.NewListSettingsPager(
+		azappconfig.SettingSelector{
+			KeyFilter:   to.Ptr(keyFilter),
+			LabelFilter: to.Ptr(labelFilter),
+			Fields:      azappconfig.AllSettingFields(),
+		},
+		&azappconfig.ListSettingsOptions{MatchConditions: conditions},
+	)
+
+	listing := &Listing{Fetched: time.Now()}
+	changed := earlier == nil
+
+	for pager.More() {
+		var raw *http.Response
+		resp, err := pager.NextPage(runtime.WithCaptureResponse(ctx, &raw))
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to get paged settings")
+		}
+
+		if raw != nil && raw.StatusCode == http.StatusNotModified {
+			listing.Pages = append(listing.Pages, earlier.Pages[len(listing.Pages)])
+			continue
+		}
+
+		changed = true
+		listing.Pages = append(listing.Pages, Page{ETag: resp.ETag, Settings: resp.Settings})
+	}
+
+	if earlier != nil && len(listing.Pages) != len(earlier.Pages) {
+		// Settings were removed from the end
+		changed = true
+	}
+
+	return listing, changed, nil
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+
+	"urbanwizardry.com/kvv/internal/recording"
+)
+
+// fakeStore serves pages of settings as App Config does, with an ETag per page, answering
+// If-None-Match with 304 Not Modified when a page hasn't changed
+type fakeStore struct {
+	lock  sync.Mutex
+	pages [][]string
+	// notModified counts the pages answered with 304
+	notModified int
+}
+
+func (f *fakeStore) setPages(pages ...[]string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.pages = pages
+	f.notModified = 0
+}
+
+func (f *fakeStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	i, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if i >= len(f.pages) {
+		http.NotFound(w, r)
+		return
+	}
+
+	items := []map[string]any{}
+	for _, key := range f.pages[i] {
+		items = append(items, map[string]any{"key": key, "value": "value of " + key, "etag": "etag-" + key})
+	}
+	body, _ := json.Marshal(map[string]any{"items": items})
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	w.Header().Set("Sync-Token", "token=1;sn=1")
+	w.Header().Set("ETag", etag)
+	if i+1 < len(f.pages) {
+		w.Header().Set("Link", fmt.Sprintf(`</kv?page=%d&api-version=2023-11-01>; rel="next"`, i+1))
+	}
+
+	if r.Header.Get("If-None-Match") == etag {
+		f.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.microsoft.appconfig.kvset+json")
+	w.Write(body)
+}
+
+func newTestClient(t *testing.T, endpoint string, transport policy.Transporter) *azappconfig.Client {
+	t.Helper()
+
+	options := &azappconfig.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Retry:     policy.RetryOptions{MaxRetries: -1},
+			Transport: transport,
+		},
+	}
+	client, err := azappconfig.NewClientFromConnectionString(
+		fmt.Sprintf("Endpoint=%s;Id=test;Secret=c2VjcmV0", endpoint),
+		options,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func keys(listing *Listing) [][]string {
+	pages := [][]string{}
+	for _, page := range listing.Pages {
+		keys := []string{}
+		for _, s := range page.Settings {
+			keys = append(keys, *s.Key)
+		}
+		pages = append(pages, keys)
+	}
+	return pages
+}
+
+func TestFetch(t *testing.T) {
+	tests := []struct {
+		name            string
+		before          [][]string
+		after           [][]string
+		wantChanged     bool
+		wantNotModified int
+	}{
+		{"nothing changed", [][]string{{"a", "b"}, {"c"}}, [][]string{{"a", "b"}, {"c"}}, false, 2},
+		{"first page changed", [][]string{{"a", "b"}, {"c"}}, [][]string{{"a", "x"}, {"c"}}, true, 1},
+		{"last page changed", [][]string{{"a", "b"}, {"c"}}, [][]string{{"a", "b"}, {"d"}}, true, 1},
+		{"page added", [][]string{{"a", "b"}}, [][]string{{"a", "b"}, {"c"}}, true, 1},
+		{"last page removed", [][]string{{"a", "b"}, {"c"}}, [][]string{{"a", "b"}}, true, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &fakeStore{}
+			server := httptest.NewServer(store)
+			defer server.Close()
+			client := newTestClient(t, server.URL, nil)
+
+			store.setPages(test.before...)
+			earlier, changed, err := Fetch(context.Background(), client, "*", "*", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !changed {
+				t.Error("first listing isn't changed")
+			}
+
+			store.setPages(test.after...)
+			latest, changed, err := Fetch(context.Background(), client, "*", "*", earlier)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if changed != test.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, test.wantChanged)
+			}
+			if store.notModified != test.wantNotModified {
+				t.Errorf("%d pages not modified, want %d", store.notModified, test.wantNotModified)
+			}
+			if got := fmt.Sprint(keys(latest)); got != fmt.Sprint(test.after) {
+				t.Errorf("listed %s, want %s", got, fmt.Sprint(test.after))
+			}
+		})
+	}
+}
+
+// TestFetchReplayed records listing a store twice, the second time with every page unchanged,
+// and checks that playing it back gives the same listings
+func TestFetchReplayed(t *testing.T) {
+	store := &fakeStore{}
+	store.setPages([]string{"a", "b"}, []string{"c"})
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder, err := recording.NewRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := newTestClient(t, server.URL, recorder)
+	first, _, err := Fetch(context.Background(), recorded, "*", "*", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Fetch(context.Background(), recorded, "*", "*", first); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	player, err := recording.NewPlayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := newTestClient(t, server.URL, player)
+
+	earlier, changed, err := Fetch(context.Background(), replayed, "*", "*", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || fmt.Sprint(keys(earlier)) != "[[a b] [c]]" {
+		t.Fatalf("replayed %v, changed %v", keys(earlier), changed)
+	}
+
+	latest, changed, err := Fetch(context.Background(), replayed, "*", "*", earlier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("replayed 304s were seen as changes")
+	}
+	if fmt.Sprint(keys(latest)) != "[[a b] [c]]" {
+		t.Errorf("replayed %v after 304s", keys(latest))
+	}
+}
@@ -0,0 +1,296 @@
+// Package config loads the config file shared by acv and accli
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the contents of the config file, e.g.
+//
+//	servers:
+//	  - my-ac-server.azconfig.io
+//	  - url: customer-ac-server.azconfig.io
+//	    connectionString: ${CUSTOMER_AC_CONNECTION_STRING}
+//	  - url: partner-ac-server.azconfig.io
+//	    credential: azure-cli
+//	    tenant: 00000000-0000-0000-0000-000000000000
+//	schemas:
+//	  - keys: myservice/*
+//	    schema: schemas/myservice.json
+//	retry:
+//	  maxRetries: 8
+//	  maxRetryDelay: 1m
+type Config struct {
+	// Servers can be chosen between in acv
+	Servers []Server `yaml:"servers"`
+	// Schemas are the JSON Schemas which the values of matching settings must conform to
+	Schemas []SchemaMapping `yaml:"schemas"`
+	// Retry tunes how requests to servers are retried
+	Retry Retry `yaml:"retry"`
+}
+
+// Retry tunes how requests which fail, or are throttled, are retried. Anything not set is
+// left at its default.
+type Retry struct {
+	// MaxRetries is how many times a request is retried, where 0 means it isn't
+	MaxRetries *int `yaml:"maxRetries"`
+	// RetryDelay is how long to wait before retrying the first time, doubling each time after,
+	// unless the server says how long to wait
+	RetryDelay time.Duration `yaml:"retryDelay"`
+	// MaxRetryDelay is the longest to wait before retrying
+	MaxRetryDelay time.Duration `yaml:"maxRetryDelay"`
+	// TryTimeout is how long to wait for each try of a request
+	TryTimeout time.Duration `yaml:"tryTimeout"`
+}
+
+// validate checks that none of the retry settings are negative
+func (r Retry) validate() error {
+	if r.MaxRetries != nil && *r.MaxRetries < 0 {
+		return errors.New("maxRetries can't be negative")
+	}
+	if r.RetryDelay < 0 || r.MaxRetryDelay < 0 || r.TryTimeout < 0 {
+		return errors.New("retry delays and timeouts can't be negative")
+	}
+
+	return nil
+}
+
+// The types of Entra ID credential a server can be authenticated with
+const (
+	DefaultCredential          = "default"
+	AzureCLICredential         = "azure-cli"
+	EnvironmentCredential      = "environment"
+	ManagedIdentityCredential  = "managed-identity"
+	WorkloadIdentityCredential = "workload-identity"
+	DeviceCodeCredential       = "device-code"
+	BrowserCredential          = "browser"
+)
+
+var credentialNames = map[string]string{
+	"":                         "Entra ID",
+	DefaultCredential:          "Entra ID",
+	AzureCLICredential:         "Azure CLI",
+	EnvironmentCredential:      "service principal",
+	ManagedIdentityCredential:  "managed identity",
+	WorkloadIdentityCredential: "workload identity",
+	DeviceCodeCredential:       "device code",
+	BrowserCredential:          "browser",
+}
+
+// Server is a configured App Config server, and how to authenticate with it
+type Server struct {
+	URL string `yaml:"url"`
+	// ConnectionString authenticates with an access key rather than Entra ID, and
+	// may refer to environment variables, e.g. ${PROD_AC_CONNECTION_STRING}
+	ConnectionString string `yaml:"connectionString"`
+	// Credential is the type of Entra ID credential, DefaultCredential if empty
+	Credential string `yaml:"credential"`
+	// TenantID is the Entra tenant to authenticate in, if not the credential's default
+	TenantID string `yaml:"tenant"`
+	// ClientID is the application or user-assigned managed identity to authenticate as, if
+	// not the credential's default
+	ClientID string `yaml:"clientId"`
+}
+
+// UnmarshalYAML allows a server to be just its URL
+func (s *Server) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&s.URL)
+	}
+
+	type plain Server
+	return node.Decode((*plain)(s))
+}
+
+// AuthMode describes how the server is authenticated with
+func (s Server) AuthMode() string {
+	if s.ConnectionString != "" {
+		return "access key"
+	}
+	return credentialNames[s.Credential]
+}
+
+// Validate checks that the server's authentication makes sense. A connection string must be
+// for the server, as the client connects to the endpoint in it rather than the server's URL.
+func (s Server) Validate() error {
+	if _, ok := credentialNames[s.Credential]; !ok {
+		return errors.Errorf("unknown credential %q", s.Credential)
+	}
+
+	if s.ConnectionString != "" {
+		endpoint, err := ConnectionStringEndpoint(s.ConnectionString)
+		if err != nil {
+			return err
+		}
+		if !sameURL(endpoint, s.URL) {
+			return errors.Errorf("the connection string is for %s, not %s", endpoint, s.URL)
+		}
+	}
+
+	switch {
+	case s.ConnectionString != "" && (s.Credential != "" || s.TenantID != "" || s.ClientID != ""):
+		return errors.New("a connection string can't be used with a credential, tenant or client ID")
+	case s.TenantID != "" && (s.Credential == EnvironmentCredential || s.Credential == ManagedIdentityCredential):
+		return errors.Errorf("a tenant can't be set for the %s credential", s.Credential)
+	case s.ClientID != "" && (s.Credential == "" || s.Credential == DefaultCredential || s.Credential == AzureCLICredential || s.Credential == EnvironmentCredential):
+		return errors.Errorf("a client ID can't be set for the %s credential", credentialNames[s.Credential])
+	}
+
+	return nil
+}
+
+// SchemaMapping maps a pattern of keys to a JSON Schema file
+type SchemaMapping struct {
+	// Keys is a key pattern, where * matches any characters, e.g. myservice/*
+	Keys string `yaml:"keys"`
+	// Label optionally limits the mapping to settings with this label
+	Label *string `yaml:"label"`
+	// Schema is the path of the schema, relative to the config file
+	Schema string `yaml:"schema"`
+}
+
+// Path is where the config file is, which is acv/config.yaml in the user's config directory
+// unless overridden by ACV_CONFIG
+func Path() (string, error) {
+	if path := os.Getenv("ACV_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find config directory")
+	}
+
+	return filepath.Join(configDir, "acv", "config.yaml"), nil
+}
+
+// Load reads the config file. A missing file is the same as an empty one.
+func Load() (Config, error) {
+	config := Config{}
+
+	path, err := Path()
+	if err != nil {
+		return config, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	} else if err != nil {
+		return config, errors.Wrap(err, "failed to read config")
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	for i, server := range config.Servers {
+		server.ConnectionString = os.ExpandEnv(server.ConnectionString)
+		if server.URL == "" && server.ConnectionString != "" {
+			server.URL, err = ConnectionStringEndpoint(server.ConnectionString)
+			if err != nil {
+				return config, errors.Wrapf(err, "%s: server %d", path, i+1)
+			}
+		}
+		if server.URL == "" {
+			return config, errors.Errorf("%s: server %d needs a url or connection string", path, i+1)
+		}
+
+		server.URL = ServerURL(server.URL)
+		if err := server.Validate(); err != nil {
+			return config, errors.Wrapf(err, "%s: server %s", path, server.URL)
+		}
+
+		config.Servers[i] = server
+	}
+
+	for i, mapping := range config.Schemas {
+		if mapping.Keys == "" || mapping.Schema == "" {
+			return config, errors.Errorf("%s: schema mapping %d needs both keys and schema", path, i+1)
+		}
+		if !filepath.IsAbs(mapping.Schema) {
+			config.Schemas[i].Schema = filepath.Join(filepath.Dir(path), mapping.Schema)
+		}
+	}
+
+	if err := config.Retry.validate(); err != nil {
+		return config, errors.Wrapf(err, "%s: retry", path)
+	}
+
+	return config, nil
+}
+
+// Server is the configured server with a URL, or if there isn't one, a server authenticated
+// with Entra ID
+func (c Config) Server(url string) Server {
+	for _, server := range c.Servers {
+		if server.URL == url {
+			return server
+		}
+	}
+
+	return Server{URL: url}
+}
+
+// UseServer puts a server first, e.g. because it was given on the command line. A configured
+// server with the same URL is replaced, although how it authenticates is kept unless the new
+// server says otherwise.
+func (c *Config) UseServer(server Server) error {
+	if err := server.Validate(); err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(c.Servers, func(s Server) bool {
+		return s.URL == server.URL
+	})
+	if i >= 0 {
+		if server.ConnectionString == "" {
+			server = c.Servers[i]
+		}
+		c.Servers = slices.Delete(c.Servers, i, i+1)
+	}
+
+	c.Servers = slices.Insert(c.Servers, 0, server)
+	return nil
+}
+
+// ConnectionStringEndpoint finds the server URL in a connection string of the form
+// Endpoint=https://...;Id=...;Secret=...
+func ConnectionStringEndpoint(connectionString string) (string, error) {
+	for _, part := range strings.Split(connectionString, ";") {
+		name, value, _ := strings.Cut(part, "=")
+		if strings.EqualFold(strings.TrimSpace(name), "Endpoint") && value != "" {
+			return ServerURL(strings.TrimSpace(value)), nil
+		}
+	}
+
+	return "", errors.New("connection string has no Endpoint")
+}
+
+// IsConnectionStringFor is whether a connection string's Endpoint is a server's URL
+func IsConnectionStringFor(connectionString string, url string) bool {
+	endpoint, err := ConnectionStringEndpoint(connectionString)
+	return err == nil && sameURL(endpoint, url)
+}
+
+// sameURL is whether two server URLs are the same server, ignoring case and a trailing slash
+func sameURL(a string, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "/"), strings.TrimSuffix(b, "/"))
+}
+
+// ServerURL makes a server into a URL, adding https:// if it isn't there
+func ServerURL(server string) string {
+	// This validation is a little weak
+	if !strings.HasPrefix(server, "https://") {
+		return fmt.Sprintf("https://%s", server)
+	}
+	return server
+}
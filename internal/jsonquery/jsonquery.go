@@ -0,0 +1,99 @@
+// Package jsonquery evaluates jq expressions against setting values
+package jsonquery
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/itchyny/gojq"
+	"github.com/pkg/errors"
+)
+
+// Evaluate runs a jq expression, e.g. `.charts[] | select(.enabled)`, against a JSON value.
+// Each result is rendered as indented JSON, one after another, as jq itself would.
+func Evaluate(value string, expression string) (string, error) {
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid query")
+	}
+
+	input, err := decode(value)
+	if err != nil {
+		return "", errors.Wrap(err, "value is not JSON")
+	}
+
+	results := []string{}
+	iter := query.Run(input)
+	for {
+		result, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := result.(error); ok {
+			var haltErr *gojq.HaltError
+			if errors.As(err, &haltErr) && haltErr.Value() == nil {
+				break
+			}
+			return "", errors.Wrap(err, "query failed")
+		}
+
+		var rendered strings.Builder
+		encoder := json.NewEncoder(&rendered)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "   ")
+		if err := encoder.Encode(result); err != nil {
+			return "", errors.Wrap(err, "failed to render query result")
+		}
+		results = append(results, strings.TrimSuffix(rendered.String(), "\n"))
+	}
+
+	return strings.Join(results, "\n"), nil
+}
+
+// decode parses a JSON value, keeping integers exact rather than rounding them to float64s, so
+// that large IDs come out of queries unchanged
+func decode(value string) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+
+	var decoded any
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+
+	return convertNumbers(decoded), nil
+}
+
+// convertNumbers replaces json.Numbers with the types gojq works with: int, *big.Int for
+// integers too large for an int, and float64 for everything else
+func convertNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil && int64(int(i)) == i {
+			return int(i)
+		}
+		if !strings.ContainsAny(v.String(), ".eE") {
+			if i, ok := new(big.Int).SetString(v.String(), 10); ok {
+				return i
+			}
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i, item := range v {
+			v[i] = convertNumbers(item)
+		}
+	case map[string]any:
+		for key, item := range v {
+			v[key] = convertNumbers(item)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,71 @@
+package jsonquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		expression string
+		want       string
+	}{
+		{"identity", `{"a": 1}`, ".", "{\n   \"a\": 1\n}"},
+		{"beyond 2^53", `9007199254740993`, ".", "9007199254740993"},
+		{"beyond int64", `12345678901234567890`, ".", "12345678901234567890"},
+		{"negative beyond int64", `-98765432109876543210`, ".", "-98765432109876543210"},
+		{"arithmetic beyond 2^53", `9007199254740993`, ". + 1", "9007199254740994"},
+		{"comparison beyond 2^53", `[9007199254740993, 9007199254740992]`, ".[0] > .[1]", "true"},
+		{"float", `1.5`, ".", "1.5"},
+		{"exponent", `1e3`, ".", "1000"},
+		{"float arithmetic", `0.5`, ". * 3", "1.5"},
+		{"nested in arrays", `[[12345678901234567890]]`, ".[0][0]", "12345678901234567890"},
+		{"nested in objects", `{"a": {"id": 12345678901234567890, "n": 2.5}}`, ".a | [.id, .n]", "[\n   12345678901234567890,\n   2.5\n]"},
+		{"several results", `[1, 2]`, ".[]", "1\n2"},
+		{"no results", `[]`, ".[]", ""},
+		{"halt", `1`, "halt", ""},
+		{"not HTML escaped", `"<a & b>"`, ".", `"<a & b>"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Evaluate(test.value, test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		expression string
+		want       string
+	}{
+		{"invalid expression", `{}`, ".[", "invalid query"},
+		{"unknown function", `{}`, "nonsense(1)", "query failed"},
+		{"not JSON", `{`, ".", "value is not JSON"},
+		{"more than one value", `1 2`, ".", "value is not JSON"},
+		{"wrong type", `1`, ".a", "query failed"},
+		{"error", `1`, `error("oops")`, "oops"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Evaluate(test.value, test.expression)
+			if err == nil {
+				t.Fatalf("got %q", got)
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error %q, want %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// Package recording captures the HTTP traffic of App Config clients to a directory, and plays
+// it back later in place of the servers, e.g. for bug reports and testing without Azure
+package recording
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// scrubbedHeaders are left out of recordings, as they authenticate requests
+var scrubbedHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// matchedHeaders are the request headers which, along with the method and URL, pick which
+// recorded response a request gets, as they change what the server sends back
+var matchedHeaders = []string{"If-Match", "If-None-Match", "Accept-Datetime"}
+
+// Interaction is a request and the response to it, saved as a file in a recording
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is a policy.Transporter which sends requests on, and saves each of them with its
+// response to a directory
+type Recorder struct {
+	dir       string
+	transport http.RoundTripper
+
+	lock sync.Mutex
+	// next numbers the next interaction saved, so that they can be read back in order
+	next int
+}
+
+// NewRecorder records into a directory, after anything already recorded there
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "failed to create recording directory")
+	}
+
+	existing, err := interactionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{
+		dir:       dir,
+		transport: http.DefaultTransport,
+		next:      len(existing) + 1,
+	}, nil
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	requestBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read request to record")
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		// Nothing came back to record
+		return nil, err
+	}
+
+	responseBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response to record")
+	}
+
+	interaction := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: scrub(req.Header),
+			Body:   requestBody,
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     scrub(resp.Header),
+			Body:       responseBody,
+		},
+	}
+
+	if err := r.save(interaction); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Recorder) save(interaction Interaction) error {
+	data, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode recording")
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	path := filepath.Join(r.dir, fmt.Sprintf("%05d.json", r.next))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return errors.Wrap(err, "failed to write recording")
+	}
+	r.next++
+	return nil
+}
+
+// Player is a policy.Transporter which answers requests with the responses recorded for them.
+// Requests made more than once get each of their recorded responses in turn, and then the
+// last one again.
+type Player struct {
+	lock      sync.Mutex
+	responses map[string][]Response
+}
+
+// NewPlayer plays back a directory recorded by a Recorder
+func NewPlayer(dir string) (*Player, error) {
+	files, err := interactionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("nothing has been recorded in %s", dir)
+	}
+
+	player := &Player{responses: map[string][]Response{}}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read recording")
+		}
+
+		var interaction Interaction
+		if err := json.Unmarshal(data, &interaction); err != nil {
+			return nil, errors.Wrapf(err, "recording %s is corrupt", file)
+		}
+
+		id := requestID(interaction.Request.Method, interaction.Request.URL, interaction.Request.Header)
+		player.responses[id] = append(player.responses[id], interaction.Response)
+	}
+
+	return player, nil
+}
+
+func (p *Player) Do(req *http.Request) (*http.Response, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	id := requestID(req.Method, req.URL.String(), req.Header)
+	responses := p.responses[id]
+	if len(responses) == 0 {
+		return nil, notRecordedError{req.Method, req.URL.String()}
+	}
+
+	recorded := responses[0]
+	if len(responses) > 1 {
+		p.responses[id] = responses[1:]
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewBufferString(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// notRecordedError is returned for a request that wasn't recorded. It isn't retried, as trying
+// again won't find a response either.
+type notRecordedError struct {
+	method string
+	url    string
+}
+
+func (e notRecordedError) Error() string {
+	return fmt.Sprintf("no response was recorded for %s %s", e.method, e.url)
+}
+
+func (notRecordedError) NonRetriable() {}
+
+// requestID is what a request is matched on when playing back
+func requestID(method string, url string, header http.Header) string {
+	id := method + " " + url
+	for _, name := range matchedHeaders {
+		id += "\n" + header.Get(name)
+	}
+	return id
+}
+
+// interactionFiles lists the recorded interactions in a directory, in the order they happened
+func interactionFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list recording")
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// readBody reads all of a body, and replaces it so that it can be read again
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return "", err
+	}
+
+	*body = io.NopCloser(bytes.NewReader(data))
+	return string(data), nil
+}
+
+func scrub(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range scrubbedHeaders {
+		header.Del(name)
+	}
+	return header
+}
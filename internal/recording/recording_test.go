@@ -0,0 +1,191 @@
+package recording
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/pkg/errors"
+)
+
+func TestRequestID(t *testing.T) {
+	header := func(pairs ...string) http.Header {
+		h := http.Header{}
+		for i := 0; i < len(pairs); i += 2 {
+			h.Set(pairs[i], pairs[i+1])
+		}
+		return h
+	}
+
+	tests := []struct {
+		name  string
+		a     http.Header
+		b     http.Header
+		match bool
+	}{
+		{"no headers", header(), header(), true},
+		{"unmatched headers are ignored", header("Authorization", "a", "x-ms-date", "1"), header("Authorization", "b", "x-ms-date", "2"), true},
+		{"If-None-Match", header("If-None-Match", `"1"`), header("If-None-Match", `"1"`), true},
+		{"different If-None-Match", header("If-None-Match", `"1"`), header("If-None-Match", `"2"`), false},
+		{"If-None-Match or not", header("If-None-Match", `"1"`), header(), false},
+		{"different If-Match", header("If-Match", `"1"`), header("If-Match", `"2"`), false},
+		{"different Accept-Datetime", header("Accept-Datetime", "Mon, 01 Jan 2024 00:00:00 GMT"), header(), false},
+		{"headers aren't confused", header("If-Match", `"1"`), header("If-None-Match", `"1"`), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := requestID(http.MethodGet, "https://example.azconfig.io/kv", test.a)
+			b := requestID(http.MethodGet, "https://example.azconfig.io/kv", test.b)
+			if (a == b) != test.match {
+				t.Errorf("matched %v, want %v", a == b, test.match)
+			}
+		})
+	}
+
+	if requestID(http.MethodGet, "https://example.azconfig.io/kv", nil) == requestID(http.MethodPut, "https://example.azconfig.io/kv", nil) {
+		t.Error("methods aren't matched")
+	}
+	if requestID(http.MethodGet, "https://example.azconfig.io/kv?key=a", nil) == requestID(http.MethodGet, "https://example.azconfig.io/kv?key=b", nil) {
+		t.Error("URLs aren't matched")
+	}
+}
+
+// record sends requests to a server through a Recorder, and returns what was recorded. The
+// server is closed afterwards, so anything played back can't have come from it.
+func record(t *testing.T, server *httptest.Server, requests ...*http.Request) string {
+	t.Helper()
+	defer server.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range requests {
+		resp, err := recorder.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	return dir
+}
+
+func newRequest(method string, url string, header ...string) *http.Request {
+	req := httptest.NewRequest(method, url, nil)
+	req.RequestURI = ""
+	for i := 0; i < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	return req
+}
+
+func TestPlayer(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("Set-Cookie", "secret")
+		if r.Header.Get("If-None-Match") == `"1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "call %d", calls)
+	}))
+	url := server.URL + "/kv"
+
+	dir := record(
+		t,
+		server,
+		newRequest(http.MethodGet, url, "Authorization", "secret"),
+		newRequest(http.MethodGet, url),
+		newRequest(http.MethodGet, url, "If-None-Match", `"1"`),
+	)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 3 {
+		t.Fatalf("recorded %d interactions, want 3", len(files))
+	}
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		if strings.Contains(string(data), "secret") {
+			t.Errorf("%s recorded authentication headers", filepath.Base(file))
+		}
+	}
+
+	player, err := NewPlayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		request    *http.Request
+		wantStatus int
+		wantBody   string
+	}{
+		{"first response", newRequest(http.MethodGet, url), http.StatusOK, "call 1"},
+		{"then the next", newRequest(http.MethodGet, url), http.StatusOK, "call 2"},
+		{"then the last again", newRequest(http.MethodGet, url), http.StatusOK, "call 2"},
+		{"not modified", newRequest(http.MethodGet, url, "If-None-Match", `"1"`), http.StatusNotModified, ""},
+		{"not modified again", newRequest(http.MethodGet, url, "If-None-Match", `"1"`), http.StatusNotModified, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := player.Do(test.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != test.wantStatus || string(body) != test.wantBody {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, test.wantStatus, test.wantBody)
+			}
+			if resp.Header.Get("ETag") != `"1"` {
+				t.Errorf("ETag %q wasn't played back", resp.Header.Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestPlayerNotRecorded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL + "/kv"
+	dir := record(t, server, newRequest(http.MethodGet, url))
+
+	player, err := NewPlayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = player.Do(newRequest(http.MethodGet, url, "If-Match", `"1"`))
+	if err == nil {
+		t.Fatal("played back a request that wasn't recorded")
+	}
+
+	// Retrying won't find it either
+	var nonRetriable interface{ NonRetriable() }
+	if !errors.As(err, &nonRetriable) {
+		t.Error("not recorded error is retriable")
+	}
+	var responseError *azcore.ResponseError
+	if errors.As(err, &responseError) {
+		t.Error("not recorded error looks like a response from the server")
+	}
+}
+
+func TestNewPlayerEmpty(t *testing.T) {
+	if _, err := NewPlayer(t.TempDir()); err == nil {
+		t.Error("played back an empty directory")
+	}
+}
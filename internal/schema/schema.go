@@ -0,0 +1,179 @@
+// Package schema validates setting values against the JSON Schemas mapped to their keys
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"urbanwizardry.com/kvv/internal/config"
+)
+
+// identifier matches object keys that can be written as .key in a JSONPath
+var identifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+var printer = message.NewPrinter(language.English)
+
+// Violation is one way in which a value doesn't conform to its schema
+type Violation struct {
+	// Path is the JSONPath of the offending part of the value, e.g. $.charts[2].name
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// Result is the outcome of validating a value against its schema
+type Result struct {
+	// Schema is the file name of the schema
+	Schema     string
+	Violations []Violation
+}
+
+// Validator validates values against the first schema whose mapping matches their key and label
+type Validator struct {
+	mappings []mapping
+}
+
+type mapping struct {
+	keys   *regexp.Regexp
+	label  *string
+	name   string
+	schema *jsonschema.Schema
+}
+
+// NewValidator compiles the schemas of the mappings
+func NewValidator(mappings []config.SchemaMapping) (*Validator, error) {
+	validator := &Validator{}
+	compiler := jsonschema.NewCompiler()
+
+	for _, m := range mappings {
+		compiled, err := compiler.Compile(m.Schema)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load schema %s", m.Schema)
+		}
+
+		validator.mappings = append(validator.mappings, mapping{
+			keys:   keyPattern(m.Keys),
+			label:  m.Label,
+			name:   filepath.Base(m.Schema),
+			schema: compiled,
+		})
+	}
+
+	return validator, nil
+}
+
+// keyPattern converts a key pattern, where * matches any characters, to a regular expression
+func keyPattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	return regexp.MustCompile(fmt.Sprintf("^%s$", strings.Join(parts, ".*")))
+}
+
+// Validate validates a setting value against its schema, returning nil if no schema applies
+func (v *Validator) Validate(key string, label *string, value string) *Result {
+	for _, m := range v.mappings {
+		if !m.keys.MatchString(key) {
+			continue
+		}
+		if m.label != nil && *m.label != labelOf(label) {
+			continue
+		}
+
+		return &Result{
+			Schema:     m.name,
+			Violations: validate(m.schema, value),
+		}
+	}
+
+	return nil
+}
+
+func validate(schema *jsonschema.Schema, value string) []Violation {
+	instance, err := jsonschema.UnmarshalJSON(strings.NewReader(value))
+	if err != nil {
+		return []Violation{{Path: "$", Message: fmt.Sprintf("not valid JSON: %s", err)}}
+	}
+
+	err = schema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+
+	var validationError *jsonschema.ValidationError
+	if !errors.As(err, &validationError) {
+		return []Violation{{Path: "$", Message: err.Error()}}
+	}
+
+	return violations(validationError, instance)
+}
+
+// violations flattens a tree of validation errors to the errors at its leaves, which are
+// the specific problems rather than e.g. "allOf failed"
+func violations(e *jsonschema.ValidationError, instance any) []Violation {
+	if len(e.Causes) == 0 {
+		return []Violation{{
+			Path:    jsonPath(instance, e.InstanceLocation),
+			Message: e.ErrorKind.LocalizedString(printer),
+		}}
+	}
+
+	found := []Violation{}
+	for _, cause := range e.Causes {
+		found = append(found, violations(cause, instance)...)
+	}
+	return found
+}
+
+// jsonPath converts the tokens of a JSON Pointer to a JSONPath, looking at the instance
+// to tell array indexes from object keys which happen to be numbers
+func jsonPath(instance any, location []string) string {
+	path := "$"
+	for _, token := range location {
+		switch container := instance.(type) {
+		case []any:
+			path = fmt.Sprintf("%s[%s]", path, token)
+			if i, err := strconv.Atoi(token); err == nil && i < len(container) {
+				instance = container[i]
+			}
+		case map[string]any:
+			path = JSONPathChild(path, token)
+			instance = container[token]
+		default:
+			path = JSONPathChild(path, token)
+		}
+	}
+
+	return path
+}
+
+// JSONPathChild is the JSONPath of an object member, e.g. $.charts or $["my key"]
+func JSONPathChild(path string, key string) string {
+	if identifier.MatchString(key) {
+		return fmt.Sprintf("%s.%s", path, key)
+	}
+
+	quoted, _ := json.Marshal(key)
+	return fmt.Sprintf("%s[%s]", path, quoted)
+}
+
+func labelOf(label *string) string {
+	if label == nil {
+		return ""
+	}
+	return *label
+}
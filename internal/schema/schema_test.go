@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"urbanwizardry.com/kvv/internal/config"
+)
+
+const strictSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"charts": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}}}},
+		"0": {"type": "string"},
+		"my key": {"type": "integer"}
+	}
+}`
+
+// newTestValidator writes schemas to files, and maps keys to them as the config file would
+func newTestValidator(t *testing.T, schemas map[string]string, mappings ...config.SchemaMapping) *Validator {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, schema := range schemas {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(schema), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range mappings {
+		mappings[i].Schema = filepath.Join(dir, mappings[i].Schema)
+	}
+
+	validator, err := NewValidator(mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return validator
+}
+
+func TestValidate(t *testing.T) {
+	prod := "prod"
+	dev := "dev"
+	empty := ""
+
+	validator := newTestValidator(
+		t,
+		map[string]string{"prod.json": strictSchema, "nolabel.json": strictSchema, "any.json": `{}`},
+		config.SchemaMapping{Keys: "app.(1)*", Label: &prod, Schema: "prod.json"},
+		config.SchemaMapping{Keys: "app.(1)*", Label: &empty, Schema: "nolabel.json"},
+		config.SchemaMapping{Keys: "*", Schema: "any.json"},
+	)
+
+	tests := []struct {
+		name       string
+		key        string
+		label      *string
+		value      string
+		wantSchema string
+		wantPaths  []string
+	}{
+		{"label", "app.(1):x", &prod, `{"name": "x"}`, "prod.json", nil},
+		{"no label", "app.(1):x", nil, `{"name": "x"}`, "nolabel.json", nil},
+		{"empty label is no label", "app.(1):x", &empty, `{"name": "x"}`, "nolabel.json", nil},
+		{"other label", "app.(1):x", &dev, `{"name": 1}`, "any.json", nil},
+		{"dot isn't any character", "appx(1):x", &prod, `{"name": 1}`, "any.json", nil},
+		{"parentheses aren't a group", "app.1:x", &prod, `{"name": 1}`, "any.json", nil},
+		{"star matches nothing", "app.(1)", &prod, `{"name": 1}`, "prod.json", []string{"$.name"}},
+		{"object key", "app.(1):x", &prod, `{"name": 1}`, "prod.json", []string{"$.name"}},
+		{"array index", "app.(1):x", &prod, `{"charts": [{"name": "a"}, {"name": 1}]}`, "prod.json", []string{"$.charts[1].name"}},
+		{"numeric object key", "app.(1):x", &prod, `{"0": 1}`, "prod.json", []string{`$["0"]`}},
+		{"key with a space", "app.(1):x", &prod, `{"my key": "x"}`, "prod.json", []string{`$["my key"]`}},
+		{"several", "app.(1):x", &prod, `{"name": 1, "0": 1}`, "prod.json", []string{"$.name", `$["0"]`}},
+		{"not JSON", "app.(1):x", &prod, `{`, "prod.json", []string{"$"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := validator.Validate(test.key, test.label, test.value)
+			if result == nil {
+				t.Fatal("no schema applied")
+			}
+			if result.Schema != test.wantSchema {
+				t.Errorf("validated with %s, want %s", result.Schema, test.wantSchema)
+			}
+
+			paths := []string{}
+			for _, violation := range result.Violations {
+				paths = append(paths, violation.Path)
+			}
+			slices.Sort(paths)
+			if !slices.Equal(paths, test.wantPaths) {
+				t.Errorf("violations %v, want at %v", result.Violations, test.wantPaths)
+			}
+		})
+	}
+}
+
+func TestValidateUnmapped(t *testing.T) {
+	validator := newTestValidator(
+		t,
+		map[string]string{"schema.json": strictSchema},
+		config.SchemaMapping{Keys: "app:*", Schema: "schema.json"},
+	)
+
+	if result := validator.Validate("other:x", nil, `{"name": 1}`); result != nil {
+		t.Errorf("validated with %s", result.Schema)
+	}
+}
+
+func TestKeyPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{"*", "anything", true},
+		{"*", "", true},
+		{"", "", true},
+		{"", "a", false},
+		{"app:*", "app:a:b", true},
+		{"app:*", "other:app:a", false},
+		{"*:name", "app:name", true},
+		{"*:name", "app:name:x", false},
+		{"a*b*c", "a-b-c", true},
+		{"a*b*c", "acb", false},
+		{"a.b", "axb", false},
+		{"a+", "aa", false},
+		{"a+", "a+", true},
+		{"[ab]*", "a", false},
+		{"[ab]*", "[ab]x", true},
+		{"a?", "a", false},
+		{`a\*`, `a\b`, true},
+		{"^a$*", "^a$", true},
+	}
+
+	for _, test := range tests {
+		if got := keyPattern(test.pattern).MatchString(test.key); got != test.match {
+			t.Errorf("%q matched %q: %v, want %v", test.pattern, test.key, got, test.match)
+		}
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	instance := map[string]any{
+		"list":    []any{"a", map[string]any{"1": "b"}},
+		"2":       []any{"c"},
+		"my key":  "d",
+		"_ok$":    "e",
+		"quote\"": "f",
+	}
+
+	tests := []struct {
+		location []string
+		want     string
+	}{
+		{nil, "$"},
+		{[]string{"list"}, "$.list"},
+		{[]string{"list", "0"}, "$.list[0]"},
+		{[]string{"list", "1", "1"}, `$.list[1]["1"]`},
+		{[]string{"2", "0"}, `$["2"][0]`},
+		{[]string{"my key"}, `$["my key"]`},
+		{[]string{"_ok$"}, "$._ok$"},
+		{[]string{`quote"`}, `$["quote\""]`},
+		{[]string{"missing", "0"}, `$.missing["0"]`},
+	}
+
+	for _, test := range tests {
+		if got := jsonPath(instance, test.location); got != test.want {
+			t.Errorf("%q is %s, want %s", test.location, got, test.want)
+		}
+	}
+}